@@ -5,6 +5,7 @@ import (
 	"manager/internal/discord"
 	"manager/internal/helpers"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
@@ -17,6 +18,12 @@ type App struct {
 	rpcConnected bool
 	closeToTray  bool
 	rpcStop      chan struct{}
+	watcher      *spicetifyWatcher
+	servicesMu   sync.Mutex // guards paletteSync and scheduler
+	paletteSync  *paletteSync
+	scheduler    *colorScheduler
+	colorPreview colorPreview
+	themePreview themePreview
 	AssetHandler http.Handler
 }
 
@@ -30,6 +37,11 @@ func (a *App) Startup(ctx context.Context) {
 
 	StartWSServer()
 	a.InstallSpicetifyXExtension()
+	a.watchPlayback()
+	a.startFolderWatcher()
+	if readColorSchedule().Enabled {
+		a.startScheduler()
+	}
 
 	settings, err := ReadSettings()
 	if err == nil {
@@ -37,6 +49,9 @@ func (a *App) Startup(ctx context.Context) {
 		if settings.DiscordRpc {
 			a.startDiscordRpc()
 		}
+		if settings.PaletteSync {
+			a.startPaletteSync(false)
+		}
 	}
 }
 
@@ -98,7 +113,16 @@ func (a *App) discordConnectLoop() {
 }
 
 func (a *App) Shutdown(ctx context.Context) {
+	a.cancelThemePreviewOnExit()
+	a.revertColorPreviewOnExit()
+	a.stopFolderWatcher()
+	a.stopPaletteSync()
+	a.stopScheduler()
 	a.stopDiscordRpc()
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	StopWSServer(shutdownCtx)
 }
 
 func (a *App) BeforeClose(ctx context.Context) bool {
@@ -5,9 +5,7 @@ import (
 	"manager/internal/helpers"
 	"os"
 	"path/filepath"
-	"regexp"
 	"slices"
-	"strings"
 )
 
 type AppInfo struct {
@@ -27,20 +25,7 @@ type appMeta struct {
 }
 
 func (a *App) GetSpicetifyApps() []AppInfo {
-	configPath := helpers.GetConfigFilePath()
-	var enabledApps []string
-
-	if data, err := os.ReadFile(configPath); err == nil {
-		re := regexp.MustCompile(`(?m)^custom_apps\s*=\s*(.*)$`)
-		if m := re.FindSubmatch(data); len(m) > 1 {
-			for _, app := range strings.Split(strings.TrimSpace(string(m[1])), "|") {
-				app = strings.TrimSpace(app)
-				if app != "" {
-					enabledApps = append(enabledApps, app)
-				}
-			}
-		}
-	}
+	enabledApps := helpers.ReadSpicetifyConfig().CustomApps()
 
 	customAppsDir := helpers.GetCustomAppsDir()
 	apps := []AppInfo{}
@@ -87,25 +72,21 @@ func (a *App) GetSpicetifyApps() []AppInfo {
 }
 
 func (a *App) ToggleSpicetifyApp(appID string, enable bool) bool {
-	exec := helpers.GetSpicetifyExec()
-
-	var args []string
+	var tx *configTransaction
 	if enable {
-		args = []string{"config", "custom_apps", appID}
+		tx = newConfigTransaction("Enable custom app "+appID).Append("custom_apps", appID)
 	} else {
-		args = []string{"config", "custom_apps", appID + "-"}
+		tx = newConfigTransaction("Disable custom app "+appID).Remove("custom_apps", appID)
 	}
 
-	if err := helpers.SpicetifyCommand(exec, args, nil); err != nil {
+	if _, err := tx.Commit(); err != nil {
 		return false
 	}
 	return true
 }
 
 func (a *App) DeleteSpicetifyApp(appID string) bool {
-	exec := helpers.GetSpicetifyExec()
-
-	_ = helpers.SpicetifyCommand(exec, []string{"config", "custom_apps", appID + "-"}, nil)
+	_ = changeSpicetifyConfig("Delete custom app "+appID, []string{"custom_apps", appID + "-"})
 	_ = os.RemoveAll(filepath.Join(helpers.GetCustomAppsDir(), appID))
 	return true
 }
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"bytes"
+	"log"
+	"manager/extension"
+	"manager/internal/helpers"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// BridgeExtensionStatus tells whether spicetifyx.js can reach the manager.
+// UpToDate compares the copy in the Extensions folder with what the manager
+// would write now; Applied compares the copy spicetify put into Spotify.
+type BridgeExtensionStatus struct {
+	Installed     bool   `json:"installed"`
+	Enabled       bool   `json:"enabled"`
+	UpToDate      bool   `json:"upToDate"`
+	Applied       bool   `json:"applied"`
+	SourceHash    string `json:"sourceHash"`
+	InstalledHash string `json:"installedHash,omitempty"`
+}
+
+func bridgeExtensionContent() []byte {
+	return extension.Render(bridgePorts(), getBridgeToken())
+}
+
+// InstallSpicetifyXExtension writes the bridge extension and registers it,
+// skipping both steps when they are already done so startup does not touch
+// the config for nothing.
+func (a *App) InstallSpicetifyXExtension() bool {
+	extDir := helpers.GetExtensionsDir()
+	if err := os.MkdirAll(extDir, 0755); err != nil {
+		return false
+	}
+
+	destPath := filepath.Join(extDir, extension.FileName)
+	content := bridgeExtensionContent()
+	if current, err := os.ReadFile(destPath); err != nil || !bytes.Equal(current, content) {
+		if err := os.WriteFile(destPath, content, 0644); err != nil {
+			return false
+		}
+		log.Printf("[LivePreview] Wrote %s (source %s)\n", extension.FileName, extension.Hash())
+	}
+
+	if !slices.Contains(helpers.ReadSpicetifyConfig().Extensions(), extension.FileName) {
+		_ = helpers.SpicetifyConfig([]string{"extensions", extension.FileName})
+	}
+	return true
+}
+
+func bridgeExtensionStatus(spotifyPath string) BridgeExtensionStatus {
+	status := BridgeExtensionStatus{SourceHash: extension.Hash()}
+	want := bridgeExtensionContent()
+
+	installed, err := os.ReadFile(filepath.Join(helpers.GetExtensionsDir(), extension.FileName))
+	if err == nil {
+		status.Installed = true
+		status.InstalledHash = extension.InstalledHash(installed)
+		status.UpToDate = bytes.Equal(installed, want)
+	}
+	status.Enabled = slices.Contains(helpers.ReadSpicetifyConfig().Extensions(), extension.FileName)
+
+	applied, err := os.ReadFile(filepath.Join(spotifyPath, "Apps", "xpui", "extensions", extension.FileName))
+	status.Applied = err == nil && bytes.Equal(applied, want)
+	return status
+}
@@ -0,0 +1,294 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"manager/internal/wshub"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// bridgeProtocolVersion is bumped whenever a message changes shape. The
+// extension sends its own version in every message.
+const bridgeProtocolVersion = 1
+
+const (
+	// manager → extension
+	msgColorUpdate   = "color_update"
+	msgColorsUpdate  = "colors_update"
+	msgCSSUpdate     = "css_update"
+	msgStatusRequest = "status_request"
+
+	// extension → manager
+	msgHello  = "hello"
+	msgStatus = "status"
+	msgError  = "error"
+
+	// both ways
+	msgAck = "ack"
+)
+
+// bridgeHeader starts every message. ID is set when the sender wants an
+// answer; the answer carries it back in ReplyTo.
+type bridgeHeader struct {
+	V       int    `json:"v"`
+	Type    string `json:"type"`
+	ID      string `json:"id,omitempty"`
+	ReplyTo string `json:"replyTo,omitempty"`
+}
+
+func newBridgeHeader(msgType string) bridgeHeader {
+	return bridgeHeader{V: bridgeProtocolVersion, Type: msgType}
+}
+
+func (h *bridgeHeader) header() *bridgeHeader { return h }
+
+// bridgeMessage is any typed message; all of them embed bridgeHeader.
+type bridgeMessage interface {
+	header() *bridgeHeader
+}
+
+type colorUpdateMessage struct {
+	bridgeHeader
+	ThemeID string `json:"themeID"`
+	Preset  string `json:"preset"`
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+}
+
+// colorsUpdateMessage carries a whole scheme. It replaces the previous one:
+// keys it does not list fall back to the saved colors.css.
+type colorsUpdateMessage struct {
+	bridgeHeader
+	ThemeID string            `json:"themeID"`
+	Preset  string            `json:"preset"`
+	Colors  map[string]string `json:"colors"`
+}
+
+// cssUpdateMessage replaces the theme layer when CSS is set and the snippet
+// layer when Snippet is set. Reset drops both.
+type cssUpdateMessage struct {
+	bridgeHeader
+	ThemeID string  `json:"themeID,omitempty"`
+	CSS     *string `json:"css,omitempty"`
+	Snippet *string `json:"snippet,omitempty"`
+	Reset   bool    `json:"reset,omitempty"`
+}
+
+type ackMessage struct {
+	bridgeHeader
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// ClientReport is what the extension reports about the Spotify it runs in.
+type ClientReport struct {
+	SpotifyVersion   string            `json:"spotifyVersion"`
+	SpicetifyVersion string            `json:"spicetifyVersion"`
+	Theme            string            `json:"theme"`
+	ColorScheme      string            `json:"colorScheme"`
+	Extensions       []string          `json:"extensions"`
+	Variables        map[string]string `json:"variables"`
+}
+
+type reportMessage struct {
+	bridgeHeader
+	ClientReport
+}
+
+// ClientError is an uncaught error ("error"), unhandled rejection
+// ("rejection") or console.error call ("console") inside Spotify.
+type ClientError struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind,omitempty"`
+	Message string    `json:"message"`
+	Source  string    `json:"source,omitempty"`
+	Line    int       `json:"line,omitempty"`
+	Column  int       `json:"column,omitempty"`
+	Stack   string    `json:"stack,omitempty"`
+}
+
+type errorMessage struct {
+	bridgeHeader
+	ClientError
+}
+
+const maxClientErrors = 20
+
+// ClientStatus describes one connected Spotify client.
+type ClientStatus struct {
+	ID              string        `json:"id"`
+	RemoteAddr      string        `json:"remoteAddr"`
+	ConnectedAt     time.Time     `json:"connectedAt"`
+	LastSeen        time.Time     `json:"lastSeen"`
+	ProtocolVersion int           `json:"protocolVersion"`
+	Compatible      bool          `json:"compatible"`
+	Report          *ClientReport `json:"report,omitempty"`
+	Errors          []ClientError `json:"errors"`
+}
+
+// bridgeClients tracks what each connected extension has told us and the
+// requests waiting for an answer.
+var bridgeClients = struct {
+	mu      sync.Mutex
+	nextID  int
+	clients map[*wshub.Client]*ClientStatus
+	pending map[string]chan json.RawMessage
+}{
+	clients: make(map[*wshub.Client]*ClientStatus),
+	pending: make(map[string]chan json.RawMessage),
+}
+
+func bridgeClientStatus(c *wshub.Client) *ClientStatus {
+	status, ok := bridgeClients.clients[c]
+	if !ok {
+		bridgeClients.nextID++
+		status = &ClientStatus{
+			ID:          "client-" + strconv.Itoa(bridgeClients.nextID),
+			RemoteAddr:  c.RemoteAddr(),
+			ConnectedAt: time.Now(),
+			Errors:      []ClientError{},
+		}
+		bridgeClients.clients[c] = status
+	}
+	status.LastSeen = time.Now()
+	return status
+}
+
+// forgetBridgeClient drops state for a client once it disconnects.
+func forgetBridgeClient(c *wshub.Client) {
+	bridgeClients.mu.Lock()
+	delete(bridgeClients.clients, c)
+	bridgeClients.mu.Unlock()
+	clearPlayback(c)
+}
+
+// handleBridgeMessage runs on the client's read goroutine for everything
+// the extension sends.
+func handleBridgeMessage(c *wshub.Client, data []byte) {
+	var header bridgeHeader
+	if err := json.Unmarshal(data, &header); err != nil {
+		log.Printf("[Bridge] Ignoring malformed message from %s: %v\n", c.RemoteAddr(), err)
+		return
+	}
+
+	bridgeClients.mu.Lock()
+	status := bridgeClientStatus(c)
+	status.ProtocolVersion = header.V
+	status.Compatible = header.V == bridgeProtocolVersion
+	switch header.Type {
+	case msgHello, msgStatus:
+		var msg reportMessage
+		if err := json.Unmarshal(data, &msg); err == nil {
+			status.Report = &msg.ClientReport
+		}
+		if header.Type == msgHello && !status.Compatible {
+			log.Printf("[Bridge] %s speaks protocol v%d, manager v%d\n", status.ID, header.V, bridgeProtocolVersion)
+		}
+	case msgError:
+		var msg errorMessage
+		if err := json.Unmarshal(data, &msg); err == nil {
+			msg.Time = time.Now()
+			status.Errors = append(status.Errors, msg.ClientError)
+			if len(status.Errors) > maxClientErrors {
+				status.Errors = status.Errors[len(status.Errors)-maxClientErrors:]
+			}
+			recordClientProblem(status.ID, msg.ClientError)
+		}
+	}
+	clientID := status.ID
+	bridgeClients.mu.Unlock()
+
+	if header.Type == msgPlayback {
+		updatePlayback(c, clientID, data)
+	}
+
+	// Answers are handed over after the state update so a waiting
+	// request sees what the reply reported.
+	if header.ReplyTo != "" {
+		bridgeClients.mu.Lock()
+		reply, ok := bridgeClients.pending[header.ReplyTo]
+		bridgeClients.mu.Unlock()
+		if ok {
+			select {
+			case reply <- json.RawMessage(data):
+			default:
+			}
+		}
+	} else if header.ID != "" && header.Type != msgAck {
+		ack := &ackMessage{bridgeHeader: newBridgeHeader(msgAck), OK: true}
+		ack.ReplyTo = header.ID
+		_ = c.Send(ack)
+	}
+}
+
+// bridgeRequest sends msg to one client and waits for the message that
+// answers it, which is either an ack or a typed reply.
+func bridgeRequest(ctx context.Context, c *wshub.Client, msg bridgeMessage) (json.RawMessage, error) {
+	header := msg.header()
+	bridgeClients.mu.Lock()
+	bridgeClients.nextID++
+	header.ID = "mgr-" + strconv.Itoa(bridgeClients.nextID)
+	reply := make(chan json.RawMessage, 1)
+	bridgeClients.pending[header.ID] = reply
+	bridgeClients.mu.Unlock()
+
+	defer func() {
+		bridgeClients.mu.Lock()
+		delete(bridgeClients.pending, header.ID)
+		bridgeClients.mu.Unlock()
+	}()
+
+	if err := c.Send(msg); err != nil {
+		return nil, err
+	}
+	select {
+	case data := <-reply:
+		var ack ackMessage
+		if err := json.Unmarshal(data, &ack); err == nil && ack.Type == msgAck && !ack.OK {
+			return data, errors.New(ack.Error)
+		}
+		return data, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%s did not answer: %w", c.RemoteAddr(), ctx.Err())
+	}
+}
+
+// GetClientStatus asks every connected Spotify client for a fresh report
+// and returns what each one has told the manager. Clients that do not
+// answer within two seconds keep their last report.
+func (a *App) GetClientStatus() []ClientStatus {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, c := range wsServer.hub.Clients() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := newBridgeHeader(msgStatusRequest)
+			if _, err := bridgeRequest(ctx, c, &req); err != nil {
+				log.Printf("[Bridge] Status request failed: %v\n", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	bridgeClients.mu.Lock()
+	defer bridgeClients.mu.Unlock()
+	statuses := []ClientStatus{}
+	for _, status := range bridgeClients.clients {
+		snapshot := *status
+		snapshot.Errors = append([]ClientError{}, status.Errors...)
+		statuses = append(statuses, snapshot)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].ConnectedAt.Before(statuses[j].ConnectedAt)
+	})
+	return statuses
+}
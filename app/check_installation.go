@@ -9,10 +9,11 @@ import (
 )
 
 type InstallStatus struct {
-	Spotify        bool `json:"spotify"`
-	Spicetify      bool `json:"spicetify"`
-	Patched        bool `json:"patched"`
-	MicrosoftStore bool `json:"microsoft_store"`
+	Spotify        bool                  `json:"spotify"`
+	Spicetify      bool                  `json:"spicetify"`
+	Patched        bool                  `json:"patched"`
+	MicrosoftStore bool                  `json:"microsoft_store"`
+	Bridge         BridgeExtensionStatus `json:"bridge"`
 }
 
 // isActuallyPatched checks whether spicetify's injection is present in Spotify's
@@ -73,5 +74,6 @@ func (a *App) CheckInstallation() InstallStatus {
 		Spicetify:      spicetifyInstalled,
 		Patched:        alreadyPatched,
 		MicrosoftStore: microsoftStore,
+		Bridge:         bridgeExtensionStatus(spotifyPath),
 	}
 }
@@ -0,0 +1,177 @@
+package app
+
+import (
+	"manager/extension"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxClientProblems bounds the rolling log; the oldest entries go first.
+const maxClientProblems = 500
+
+// ClientProblem is an error, unhandled rejection or console.error from
+// inside Spotify. Repeats of the same message from the same place are
+// folded into one entry.
+type ClientProblem struct {
+	ID        int       `json:"id"`
+	Kind      string    `json:"kind"`
+	Extension string    `json:"extension,omitempty"`
+	Message   string    `json:"message"`
+	Source    string    `json:"source,omitempty"`
+	Line      int       `json:"line,omitempty"`
+	Column    int       `json:"column,omitempty"`
+	Stack     string    `json:"stack,omitempty"`
+	Client    string    `json:"client"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Count     int       `json:"count"`
+}
+
+type ProblemFilter struct {
+	Extension string    `json:"extension,omitempty"`
+	Kind      string    `json:"kind,omitempty"`
+	Since     time.Time `json:"since,omitempty"`
+}
+
+type ExtensionProblems struct {
+	Extension   string    `json:"extension"`
+	Count       int       `json:"count"`
+	LastSeen    time.Time `json:"lastSeen"`
+	LastMessage string    `json:"lastMessage"`
+}
+
+var clientProblems = struct {
+	mu      sync.Mutex
+	nextID  int
+	entries []*ClientProblem
+}{}
+
+// extensionPathPattern finds the extension file in a script URL or stack
+// frame, e.g. https://xpui.app.spotify.com/extensions/fullAppDisplay.js:12:3.
+var extensionPathPattern = regexp.MustCompile(`/extensions/([^/?#:\s)]+\.m?js)`)
+
+// attributeProblem names the extension a problem came from: the script
+// that threw, else the first extension frame in the stack. Frames of the
+// bridge itself are skipped there since its console.error hook sits on
+// every captured stack. Errors raised by Spotify itself stay unattributed.
+func attributeProblem(source, stack string) string {
+	if m := extensionPathPattern.FindStringSubmatch(source); m != nil {
+		return extensionName(m[1])
+	}
+	for _, m := range extensionPathPattern.FindAllStringSubmatch(stack, -1) {
+		if name := extensionName(m[1]); name != extension.FileName {
+			return name
+		}
+	}
+	return ""
+}
+
+func extensionName(match string) string {
+	if name, err := url.PathUnescape(match); err == nil {
+		return path.Base(name)
+	}
+	return match
+}
+
+func recordClientProblem(client string, e ClientError) {
+	kind := e.Kind
+	if kind == "" {
+		kind = "error"
+	}
+	now := time.Now()
+	extension := attributeProblem(e.Source, e.Stack)
+
+	clientProblems.mu.Lock()
+	defer clientProblems.mu.Unlock()
+	for _, p := range clientProblems.entries {
+		if p.Kind == kind && p.Message == e.Message && p.Source == e.Source && p.Line == e.Line {
+			p.Count++
+			p.LastSeen = now
+			p.Client = client
+			return
+		}
+	}
+
+	clientProblems.nextID++
+	clientProblems.entries = append(clientProblems.entries, &ClientProblem{
+		ID:        clientProblems.nextID,
+		Kind:      kind,
+		Extension: extension,
+		Message:   e.Message,
+		Source:    e.Source,
+		Line:      e.Line,
+		Column:    e.Column,
+		Stack:     e.Stack,
+		Client:    client,
+		FirstSeen: now,
+		LastSeen:  now,
+		Count:     1,
+	})
+	if len(clientProblems.entries) > maxClientProblems {
+		clientProblems.entries = clientProblems.entries[len(clientProblems.entries)-maxClientProblems:]
+	}
+}
+
+// GetClientProblems returns logged problems matching filter, most recent
+// first. An empty filter returns everything.
+func (a *App) GetClientProblems(filter ProblemFilter) []ClientProblem {
+	clientProblems.mu.Lock()
+	defer clientProblems.mu.Unlock()
+	problems := []ClientProblem{}
+	for _, p := range clientProblems.entries {
+		if filter.Extension != "" && !strings.EqualFold(p.Extension, filter.Extension) {
+			continue
+		}
+		if filter.Kind != "" && p.Kind != filter.Kind {
+			continue
+		}
+		if !filter.Since.IsZero() && p.LastSeen.Before(filter.Since) {
+			continue
+		}
+		problems = append(problems, *p)
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].LastSeen.After(problems[j].LastSeen)
+	})
+	return problems
+}
+
+// GetProblemsByExtension totals the log per extension so the problems view
+// can point at the one misbehaving. Unattributed problems are grouped under
+// an empty name.
+func (a *App) GetProblemsByExtension() []ExtensionProblems {
+	clientProblems.mu.Lock()
+	defer clientProblems.mu.Unlock()
+	byName := map[string]*ExtensionProblems{}
+	for _, p := range clientProblems.entries {
+		summary, ok := byName[p.Extension]
+		if !ok {
+			summary = &ExtensionProblems{Extension: p.Extension}
+			byName[p.Extension] = summary
+		}
+		summary.Count += p.Count
+		if p.LastSeen.After(summary.LastSeen) {
+			summary.LastSeen = p.LastSeen
+			summary.LastMessage = p.Message
+		}
+	}
+	summaries := []ExtensionProblems{}
+	for _, summary := range byName {
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Count > summaries[j].Count
+	})
+	return summaries
+}
+
+func (a *App) ClearClientProblems() {
+	clientProblems.mu.Lock()
+	clientProblems.entries = nil
+	clientProblems.mu.Unlock()
+}
@@ -0,0 +1,235 @@
+package app
+
+import (
+	"fmt"
+	"manager/internal/spicecolor"
+	"math"
+	"slices"
+)
+
+const (
+	ContrastLevelAA  = "AA"
+	ContrastLevelAAA = "AAA"
+)
+
+// contrastPair is a foreground drawn on a background somewhere in the
+// Spotify UI. UI pairs are icons and controls rather than body text and use
+// the WCAG non-text / large text thresholds.
+type contrastPair struct {
+	fg, bg string
+	ui     bool
+}
+
+var contrastPairs = []contrastPair{
+	{fg: "text", bg: "main"},
+	{fg: "text", bg: "main-elevated"},
+	{fg: "text", bg: "highlight"},
+	{fg: "text", bg: "sidebar"},
+	{fg: "text", bg: "player"},
+	{fg: "text", bg: "card"},
+	{fg: "text", bg: "tab-active"},
+	{fg: "text", bg: "notification"},
+	{fg: "text", bg: "notification-error"},
+	{fg: "subtext", bg: "main"},
+	{fg: "subtext", bg: "main-elevated"},
+	{fg: "subtext", bg: "sidebar"},
+	{fg: "subtext", bg: "player"},
+	{fg: "subtext", bg: "card"},
+	{fg: "button", bg: "main", ui: true},
+	{fg: "button", bg: "player", ui: true},
+	{fg: "button", bg: "sidebar", ui: true},
+	{fg: "button-active", bg: "main", ui: true},
+	{fg: "button-active", bg: "player", ui: true},
+}
+
+type ContrastCheck struct {
+	Foreground string  `json:"foreground"`
+	Background string  `json:"background"`
+	Ratio      float64 `json:"ratio"`
+	UI         bool    `json:"ui"`
+	AA         bool    `json:"aa"`
+	AAA        bool    `json:"aaa"`
+}
+
+type ContrastReport struct {
+	Theme  string          `json:"theme"`
+	Scheme string          `json:"scheme"`
+	Checks []ContrastCheck `json:"checks"`
+	// PassesAA and PassesAAA are true when every check passes that level.
+	PassesAA  bool `json:"passesAA"`
+	PassesAAA bool `json:"passesAAA"`
+}
+
+type ContrastFix struct {
+	Key  string `json:"key"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type ContrastFixResult struct {
+	Level   string         `json:"level"`
+	Applied bool           `json:"applied"`
+	Fixes   []ContrastFix  `json:"fixes"`
+	Report  ContrastReport `json:"report"`
+	// Unfixable lists foreground keys no lightness could make pass, and
+	// keys holding a ${...} reference that fail (checked with their
+	// fallback) or cannot be checked at all, or a color with alpha that
+	// fails. References and colors with alpha are never rewritten: the
+	// first would lose the reference, and color.ini cannot hold the second.
+	Unfixable []string `json:"unfixable,omitempty"`
+}
+
+func contrastThreshold(level string, ui bool) float64 {
+	switch {
+	case level == ContrastLevelAAA && ui:
+		return 4.5
+	case level == ContrastLevelAAA:
+		return 7
+	case ui:
+		return 3
+	}
+	return 4.5
+}
+
+// schemeColors resolves the concrete colors of a scheme. References are
+// checked with their fallback and also reported in refs, since fixing them
+// would replace the reference; references without a fallback and invalid
+// values are left out, so pairs using them are not checked.
+func (a *App) schemeColors(themeID, scheme string) (colors map[string]spicecolor.Color, refs []string, err error) {
+	schemes, err := a.GetColorSchemes(themeID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, s := range schemes {
+		if s.Name != scheme {
+			continue
+		}
+		colors = map[string]spicecolor.Color{}
+		for _, entry := range s.Colors {
+			v, err := spicecolor.Parse(entry.Value)
+			if err != nil {
+				continue
+			}
+			if v.IsRef() {
+				refs = append(refs, entry.Key)
+			}
+			if c, ok := v.Resolved(); ok {
+				colors[entry.Key] = c
+			}
+		}
+		return colors, refs, nil
+	}
+	return nil, nil, fmt.Errorf("scheme %s does not exist in %s", scheme, themeID)
+}
+
+func contrastReport(themeID, scheme string, colors map[string]spicecolor.Color) ContrastReport {
+	report := ContrastReport{Theme: themeID, Scheme: scheme, Checks: []ContrastCheck{}, PassesAA: true, PassesAAA: true}
+	for _, pair := range contrastPairs {
+		fg, okFg := colors[pair.fg]
+		bg, okBg := colors[pair.bg]
+		if !okFg || !okBg {
+			continue
+		}
+		ratio := spicecolor.Contrast(fg, bg)
+		check := ContrastCheck{
+			Foreground: pair.fg,
+			Background: pair.bg,
+			Ratio:      math.Round(ratio*100) / 100,
+			UI:         pair.ui,
+			AA:         ratio >= contrastThreshold(ContrastLevelAA, pair.ui),
+			AAA:        ratio >= contrastThreshold(ContrastLevelAAA, pair.ui),
+		}
+		report.PassesAA = report.PassesAA && check.AA
+		report.PassesAAA = report.PassesAAA && check.AAA
+		report.Checks = append(report.Checks, check)
+	}
+	return report
+}
+
+// AnalyzeColorSchemeContrast computes WCAG contrast ratios for the
+// foreground/background pairs a scheme's colors are used in.
+func (a *App) AnalyzeColorSchemeContrast(themeID, scheme string) (ContrastReport, error) {
+	colors, _, err := a.schemeColors(themeID, scheme)
+	if err != nil {
+		return ContrastReport{}, err
+	}
+	return contrastReport(themeID, scheme, colors), nil
+}
+
+// FixColorSchemeContrast proposes the smallest lightness change to each
+// failing foreground key that makes all of its pairs reach level. Hue,
+// chroma and backgrounds are left alone. With apply the fixes are written
+// to color.ini, otherwise they are only returned.
+func (a *App) FixColorSchemeContrast(themeID, scheme, level string, apply bool) (ContrastFixResult, error) {
+	if level != ContrastLevelAA && level != ContrastLevelAAA {
+		return ContrastFixResult{}, fmt.Errorf("unknown contrast level %q, expected AA or AAA", level)
+	}
+	colors, refs, err := a.schemeColors(themeID, scheme)
+	if err != nil {
+		return ContrastFixResult{}, err
+	}
+
+	result := ContrastFixResult{Level: level, Fixes: []ContrastFix{}}
+	var foregrounds []string
+	for _, pair := range contrastPairs {
+		if !slices.Contains(foregrounds, pair.fg) {
+			foregrounds = append(foregrounds, pair.fg)
+		}
+	}
+
+	for _, key := range foregrounds {
+		passes := func(c spicecolor.Color) bool {
+			for _, pair := range contrastPairs {
+				bg, ok := colors[pair.bg]
+				if pair.fg == key && ok && spicecolor.Contrast(c, bg) < contrastThreshold(level, pair.ui) {
+					return false
+				}
+			}
+			return true
+		}
+		fg, ok := colors[key]
+		if slices.Contains(refs, key) || (ok && fg.A != 255) {
+			if !ok || !passes(fg) {
+				result.Unfixable = append(result.Unfixable, key)
+			}
+			continue
+		}
+		if !ok {
+			continue
+		}
+		fixed, ok := spicecolor.AdjustLightness(fg, passes)
+		if !ok {
+			result.Unfixable = append(result.Unfixable, key)
+			continue
+		}
+		if fixed != fg {
+			result.Fixes = append(result.Fixes, ContrastFix{Key: key, From: fg.Hex(), To: fixed.Hex()})
+			colors[key] = fixed
+		}
+	}
+
+	if apply && len(result.Fixes) > 0 {
+		cfg, err := loadColorIni(themeID)
+		if err != nil {
+			return result, err
+		}
+		section := cfg.Section(scheme)
+		if section == nil {
+			return result, fmt.Errorf("scheme %s does not exist in %s", scheme, themeID)
+		}
+		for _, fix := range result.Fixes {
+			value, err := spicecolor.NormalizeOpaque(fix.To)
+			if err != nil {
+				return result, fmt.Errorf("%s: %w", fix.Key, err)
+			}
+			section.Set(fix.Key, value)
+		}
+		if err := cfg.Save(colorIniPath(themeID)); err != nil {
+			return result, err
+		}
+		result.Applied = true
+	}
+
+	result.Report = contrastReport(themeID, scheme, colors)
+	return result, nil
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"fmt"
+	"manager/internal/spicecolor"
+	"slices"
+)
+
+func (a *App) GetPaletteFormats() []string {
+	return spicecolor.Formats
+}
+
+// GetPaletteMapping returns the default spicetify key -> palette slot
+// mapping for a format, as a starting point for a custom one.
+func (a *App) GetPaletteMapping(format string) (map[string]string, error) {
+	if !slices.Contains(spicecolor.Formats, format) {
+		return nil, fmt.Errorf("unknown palette format %q", format)
+	}
+	return spicecolor.DefaultMapping(format), nil
+}
+
+// ImportColorScheme converts palette data into a new scheme of a theme.
+// mapping (spicetify key -> slot) defaults to the format's own; schemeName
+// defaults to the name stored in the data, if any.
+func (a *App) ImportColorScheme(themeID, schemeName, format, data string, mapping map[string]string) (ColorScheme, error) {
+	palette, name, err := spicecolor.Decode(format, data)
+	if err != nil {
+		return ColorScheme{}, err
+	}
+	if schemeName == "" {
+		schemeName = name
+	}
+	if schemeName == "" {
+		return ColorScheme{}, fmt.Errorf("a scheme name is required for %s data", format)
+	}
+	if len(mapping) == 0 && format == spicecolor.FormatCSS {
+		// --spice-* properties already carry the key name, standard or not.
+		mapping = map[string]string{}
+		for slot := range palette {
+			mapping[slot] = slot
+		}
+	} else if len(mapping) == 0 {
+		mapping = spicecolor.DefaultMapping(format)
+	}
+
+	colors := map[string]string{}
+	for key, c := range spicecolor.ToScheme(palette, mapping) {
+		colors[key] = c.Hex()
+	}
+	if len(colors) == 0 {
+		return ColorScheme{}, fmt.Errorf("the mapping matched none of the colors in the %s data", format)
+	}
+	if err := a.CreateColorScheme(themeID, schemeName, colors); err != nil {
+		return ColorScheme{}, err
+	}
+
+	schemes, err := a.GetColorSchemes(themeID)
+	if err != nil {
+		return ColorScheme{}, err
+	}
+	for _, scheme := range schemes {
+		if scheme.Name == schemeName {
+			return scheme, nil
+		}
+	}
+	return ColorScheme{}, fmt.Errorf("scheme %s was not written", schemeName)
+}
+
+// ExportColorScheme renders a scheme in a palette format. Slots the mapping
+// does not cover are filled from the closest spicetify key so the output is
+// always a complete palette. References export as their fallback color and
+// are skipped when they have none.
+func (a *App) ExportColorScheme(themeID, scheme, format string, mapping map[string]string) (string, error) {
+	if !slices.Contains(spicecolor.Formats, format) {
+		return "", fmt.Errorf("unknown palette format %q", format)
+	}
+	colors, _, err := a.schemeColors(themeID, scheme)
+	if err != nil {
+		return "", err
+	}
+	if len(mapping) == 0 {
+		mapping = spicecolor.DefaultMapping(format)
+	}
+	return spicecolor.Encode(format, scheme, spicecolor.FromScheme(format, colors, mapping))
+}
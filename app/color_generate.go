@@ -0,0 +1,59 @@
+package app
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"log"
+	"manager/internal/spicecolor"
+	"os"
+
+	_ "golang.org/x/image/webp"
+)
+
+// paletteSize is how many clusters are extracted before mapping onto the
+// standard keys; a few more than the distinct roles leaves room to pick an
+// accent.
+const paletteSize = 8
+
+// GenerateColorSchemeFromImage builds a scheme from the palette of a PNG,
+// JPEG or WebP image and writes it to the theme's color.ini as a new
+// section.
+func (a *App) GenerateColorSchemeFromImage(themeID, imagePath, schemeName string) (ColorScheme, error) {
+	f, err := os.Open(imagePath)
+	if err != nil {
+		return ColorScheme{}, err
+	}
+	defer f.Close()
+
+	img, format, err := image.Decode(f)
+	if err != nil {
+		return ColorScheme{}, fmt.Errorf("could not read %s as PNG, JPEG or WebP: %w", imagePath, err)
+	}
+
+	palette := spicecolor.ExtractPalette(img, paletteSize)
+	if len(palette) == 0 {
+		return ColorScheme{}, fmt.Errorf("%s has no opaque pixels to take colors from", imagePath)
+	}
+
+	colors := map[string]string{}
+	for key, c := range spicecolor.SchemeFromPalette(palette) {
+		colors[key] = c.Hex()
+	}
+	if err := a.CreateColorScheme(themeID, schemeName, colors); err != nil {
+		return ColorScheme{}, err
+	}
+
+	schemes, err := a.GetColorSchemes(themeID)
+	if err != nil {
+		return ColorScheme{}, err
+	}
+	for _, scheme := range schemes {
+		if scheme.Name == schemeName {
+			log.Printf("[Colors] Generated scheme %s for %s from %s image\n", schemeName, themeID, format)
+			return scheme, nil
+		}
+	}
+	return ColorScheme{}, fmt.Errorf("scheme %s was not written", schemeName)
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"manager/internal/helpers"
+	"sync"
+)
+
+// colorPreview tracks a scheme shown in Spotify but not yet saved to the
+// config. Until it is committed the saved scheme is what Spotify should go
+// back to.
+type colorPreview struct {
+	mu      sync.Mutex
+	active  bool
+	themeID string
+	scheme  string
+}
+
+type ColorPreviewStatus struct {
+	Active      bool   `json:"active"`
+	Theme       string `json:"theme,omitempty"`
+	Scheme      string `json:"scheme,omitempty"`
+	SavedScheme string `json:"savedScheme"`
+}
+
+// PreviewColorScheme recolors Spotify with every key of a scheme in one
+// message, without touching config-xpui.ini. Only schemes of the applied
+// theme can be previewed; color_scheme means nothing to another theme.
+func (a *App) PreviewColorScheme(themeID, scheme string) error {
+	if current := helpers.ReadSpicetifyConfig().CurrentTheme(); themeID != current {
+		return fmt.Errorf("%s is not the applied theme (%s)", themeID, current)
+	}
+	colors, ok := a.GetThemePresets(themeID)[scheme]
+	if !ok {
+		return fmt.Errorf("scheme %s does not exist in %s", scheme, themeID)
+	}
+	BroadcastLiveColors(themeID, scheme, colors)
+
+	a.colorPreview.mu.Lock()
+	a.colorPreview.active = true
+	a.colorPreview.themeID = themeID
+	a.colorPreview.scheme = scheme
+	a.colorPreview.mu.Unlock()
+	return nil
+}
+
+// CommitColorSchemePreview saves the previewed scheme as color_scheme.
+func (a *App) CommitColorSchemePreview() error {
+	a.colorPreview.mu.Lock()
+	defer a.colorPreview.mu.Unlock()
+	if !a.colorPreview.active {
+		return fmt.Errorf("no color scheme preview is active")
+	}
+	if current := helpers.ReadSpicetifyConfig().CurrentTheme(); a.colorPreview.themeID != current {
+		return fmt.Errorf("the applied theme changed to %s during the preview", current)
+	}
+	if !a.SetColorScheme(a.colorPreview.themeID, a.colorPreview.scheme) {
+		return fmt.Errorf("could not set color_scheme to %s", a.colorPreview.scheme)
+	}
+	a.colorPreview.active = false
+	return nil
+}
+
+// RevertColorSchemePreview pushes the saved scheme back to Spotify. Keys
+// only the previewed scheme had are cleared with it.
+func (a *App) RevertColorSchemePreview() error {
+	a.colorPreview.mu.Lock()
+	defer a.colorPreview.mu.Unlock()
+	if !a.colorPreview.active {
+		return nil
+	}
+	a.colorPreview.active = false
+	return a.broadcastSavedColorScheme()
+}
+
+// broadcastSavedColorScheme pushes the scheme config-xpui.ini points at.
+func (a *App) broadcastSavedColorScheme() error {
+	config := helpers.ReadSpicetifyConfig()
+	themeID, scheme := config.CurrentTheme(), config.ColorScheme()
+	colors, ok := a.GetThemePresets(themeID)[scheme]
+	if !ok {
+		return fmt.Errorf("saved scheme %s does not exist in %s", scheme, themeID)
+	}
+	BroadcastLiveColors(themeID, scheme, colors)
+	return nil
+}
+
+func (a *App) GetColorPreviewStatus() ColorPreviewStatus {
+	a.colorPreview.mu.Lock()
+	defer a.colorPreview.mu.Unlock()
+	status := ColorPreviewStatus{SavedScheme: helpers.ReadSpicetifyConfig().ColorScheme()}
+	if a.colorPreview.active {
+		status.Active = true
+		status.Theme = a.colorPreview.themeID
+		status.Scheme = a.colorPreview.scheme
+	}
+	return status
+}
+
+// revertColorPreviewOnExit rolls back an uncommitted preview when the
+// manager quits so Spotify is not left showing colors nobody saved.
+func (a *App) revertColorPreviewOnExit() {
+	if err := a.RevertColorSchemePreview(); err != nil {
+		log.Printf("[LivePreview] Could not revert preview: %v\n", err)
+	}
+}
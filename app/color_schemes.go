@@ -0,0 +1,304 @@
+package app
+
+import (
+	"fmt"
+	"manager/internal/helpers"
+	"manager/internal/spicecolor"
+	"manager/internal/spiceconfig"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+)
+
+// ColorEntry holds a color.ini value in canonical form. Values that do not
+// parse as a color are passed through untouched and flagged Invalid.
+type ColorEntry struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Invalid bool   `json:"invalid,omitempty"`
+}
+
+type ColorScheme struct {
+	Name   string       `json:"name"`
+	Colors []ColorEntry `json:"colors"`
+}
+
+func colorIniPath(themeID string) string {
+	return filepath.Join(helpers.GetThemesDir(), themeID, "color.ini")
+}
+
+// loadColorIni reads a theme's color.ini. A theme folder without one yields
+// an empty file so the first scheme can be created from scratch.
+func loadColorIni(themeID string) (*spiceconfig.File, error) {
+	if themeID == "" || filepath.Base(themeID) != themeID {
+		return nil, fmt.Errorf("invalid theme %q", themeID)
+	}
+	if info, err := os.Stat(filepath.Join(helpers.GetThemesDir(), themeID)); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("theme %s is not installed", themeID)
+	}
+	cfg, err := spiceconfig.Load(colorIniPath(themeID))
+	if os.IsNotExist(err) {
+		return spiceconfig.Parse(nil), nil
+	}
+	return cfg, err
+}
+
+func colorSchemeNames(themeDir string) []string {
+	cfg, err := spiceconfig.Load(filepath.Join(themeDir, "color.ini"))
+	if err != nil {
+		return nil
+	}
+	return cfg.SectionNames()
+}
+
+func firstColorScheme(themeDir string) string {
+	if schemes := colorSchemeNames(themeDir); len(schemes) > 0 {
+		return schemes[0]
+	}
+	return ""
+}
+
+// GetColorSchemes returns every scheme of a theme with its keys in file
+// order.
+func (a *App) GetColorSchemes(themeID string) ([]ColorScheme, error) {
+	cfg, err := loadColorIni(themeID)
+	if err != nil {
+		return nil, err
+	}
+	schemes := []ColorScheme{}
+	for _, section := range cfg.Sections() {
+		scheme := ColorScheme{Name: section.Name(), Colors: []ColorEntry{}}
+		for _, key := range section.Keys() {
+			raw, _ := section.Get(key)
+			entry := ColorEntry{Key: key, Value: raw}
+			if value, err := spicecolor.Normalize(raw); err == nil {
+				entry.Value = value
+			} else {
+				entry.Invalid = true
+			}
+			scheme.Colors = append(scheme.Colors, entry)
+		}
+		schemes = append(schemes, scheme)
+	}
+	return schemes, nil
+}
+
+func (a *App) GetThemePresets(themeID string) map[string]map[string]string {
+	schemes, err := a.GetColorSchemes(themeID)
+	if err != nil || !fileExists(colorIniPath(themeID)) {
+		return nil
+	}
+	presets := make(map[string]map[string]string)
+	for _, scheme := range schemes {
+		presets[scheme.Name] = make(map[string]string)
+		for _, c := range scheme.Colors {
+			presets[scheme.Name][c.Key] = c.Value
+		}
+	}
+	return presets
+}
+
+// UpdateThemePreset overwrites a key that already exists in a scheme. Use
+// SetColorSchemeKey to add keys.
+func (a *App) UpdateThemePreset(themeID, preset, key, value string) bool {
+	value, err := spicecolor.NormalizeOpaque(value)
+	if err != nil {
+		return false
+	}
+	cfg, err := loadColorIni(themeID)
+	if err != nil {
+		return false
+	}
+	section := cfg.Section(preset)
+	if section == nil || !section.Has(key) {
+		return false
+	}
+	section.Set(key, value)
+	return cfg.Save(colorIniPath(themeID)) == nil
+}
+
+// CreateColorScheme adds a new scheme at the end of color.ini. Standard
+// spicetify keys are written first in their usual order, anything else
+// follows alphabetically.
+func (a *App) CreateColorScheme(themeID, name string, colors map[string]string) error {
+	if err := spiceconfig.ValidateSchemeName(name); err != nil {
+		return err
+	}
+	cfg, err := loadColorIni(themeID)
+	if err != nil {
+		return err
+	}
+	if cfg.Section(name) != nil {
+		return fmt.Errorf("scheme %s already exists in %s", name, themeID)
+	}
+
+	keys := make([]string, 0, len(colors))
+	values := make(map[string]string, len(colors))
+	for key, raw := range colors {
+		if err := spiceconfig.ValidateColorKey(key); err != nil {
+			return err
+		}
+		value, err := spicecolor.NormalizeOpaque(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		keys = append(keys, key)
+		values[key] = value
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return colorKeyRank(keys[i], keys[j])
+	})
+
+	section := cfg.AddSection(name)
+	for _, key := range keys {
+		section.Set(key, values[key])
+	}
+	return cfg.Save(colorIniPath(themeID))
+}
+
+func colorKeyRank(a, b string) bool {
+	ia, ib := slices.Index(spiceconfig.StandardColorKeys, a), slices.Index(spiceconfig.StandardColorKeys, b)
+	switch {
+	case ia >= 0 && ib >= 0:
+		return ia < ib
+	case ia >= 0 || ib >= 0:
+		return ia >= 0
+	}
+	return a < b
+}
+
+// DuplicateColorScheme copies a scheme, comments and alignment included, so
+// it can be experimented with without touching the original.
+func (a *App) DuplicateColorScheme(themeID, from, to string) error {
+	if err := spiceconfig.ValidateSchemeName(to); err != nil {
+		return err
+	}
+	cfg, err := loadColorIni(themeID)
+	if err != nil {
+		return err
+	}
+	if cfg.Section(from) == nil {
+		return fmt.Errorf("scheme %s does not exist in %s", from, themeID)
+	}
+	if cfg.CloneSection(from, to) == nil {
+		return fmt.Errorf("scheme %s already exists in %s", to, themeID)
+	}
+	return cfg.Save(colorIniPath(themeID))
+}
+
+// RenameColorScheme renames a scheme and, if it is the active one, points
+// color_scheme at the new name.
+func (a *App) RenameColorScheme(themeID, from, to string) error {
+	if err := spiceconfig.ValidateSchemeName(to); err != nil {
+		return err
+	}
+	cfg, err := loadColorIni(themeID)
+	if err != nil {
+		return err
+	}
+	if cfg.Section(from) == nil {
+		return fmt.Errorf("scheme %s does not exist in %s", from, themeID)
+	}
+	if !cfg.RenameSection(from, to) {
+		return fmt.Errorf("scheme %s already exists in %s", to, themeID)
+	}
+	if err := cfg.Save(colorIniPath(themeID)); err != nil {
+		return err
+	}
+
+	if isActiveColorScheme(themeID, from) {
+		_, err := newConfigTransaction("Rename color scheme "+from+" to "+to).Set("color_scheme", to).Commit()
+		return err
+	}
+	return nil
+}
+
+// DeleteColorScheme removes a scheme. Deleting the active scheme switches
+// color_scheme to the first one left; the last scheme cannot be deleted.
+func (a *App) DeleteColorScheme(themeID, name string) error {
+	cfg, err := loadColorIni(themeID)
+	if err != nil {
+		return err
+	}
+	if cfg.Section(name) == nil {
+		return fmt.Errorf("scheme %s does not exist in %s", name, themeID)
+	}
+	if len(cfg.Sections()) == 1 {
+		return fmt.Errorf("%s is the only scheme of %s and cannot be deleted", name, themeID)
+	}
+	cfg.RemoveSection(name)
+	if err := cfg.Save(colorIniPath(themeID)); err != nil {
+		return err
+	}
+
+	if isActiveColorScheme(themeID, name) {
+		next := cfg.SectionNames()[0]
+		_, err := newConfigTransaction("Delete color scheme "+name).Set("color_scheme", next).Commit()
+		return err
+	}
+	return nil
+}
+
+// SetColorSchemeKey sets a key in a scheme, adding it if it is missing.
+func (a *App) SetColorSchemeKey(themeID, scheme, key, value string) error {
+	if err := spiceconfig.ValidateColorKey(key); err != nil {
+		return err
+	}
+	value, err := spicecolor.NormalizeOpaque(value)
+	if err != nil {
+		return err
+	}
+	cfg, err := loadColorIni(themeID)
+	if err != nil {
+		return err
+	}
+	section := cfg.Section(scheme)
+	if section == nil {
+		return fmt.Errorf("scheme %s does not exist in %s", scheme, themeID)
+	}
+	section.Set(key, value)
+	return cfg.Save(colorIniPath(themeID))
+}
+
+func (a *App) DeleteColorSchemeKey(themeID, scheme, key string) error {
+	cfg, err := loadColorIni(themeID)
+	if err != nil {
+		return err
+	}
+	section := cfg.Section(scheme)
+	if section == nil {
+		return fmt.Errorf("scheme %s does not exist in %s", scheme, themeID)
+	}
+	if !section.Delete(key) {
+		return fmt.Errorf("%s has no key %s", scheme, key)
+	}
+	return cfg.Save(colorIniPath(themeID))
+}
+
+// upsertColorScheme writes colors into a scheme, creating it if needed.
+// Keys of an existing scheme that colors does not mention are kept.
+func (a *App) upsertColorScheme(themeID, name string, colors map[string]string) error {
+	cfg, err := loadColorIni(themeID)
+	if err != nil {
+		return err
+	}
+	section := cfg.Section(name)
+	if section == nil {
+		return a.CreateColorScheme(themeID, name, colors)
+	}
+	for _, key := range slices.Sorted(maps.Keys(colors)) {
+		value, err := spicecolor.NormalizeOpaque(colors[key])
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		section.Set(key, value)
+	}
+	return cfg.Save(colorIniPath(themeID))
+}
+
+func isActiveColorScheme(themeID, scheme string) bool {
+	config := helpers.ReadSpicetifyConfig()
+	return config.CurrentTheme() == themeID && config.ColorScheme() == scheme
+}
@@ -0,0 +1,281 @@
+package app
+
+import (
+	"fmt"
+	"manager/internal/helpers"
+	"manager/internal/spiceconfig"
+	"maps"
+	"slices"
+	"strings"
+)
+
+const (
+	ConfigOpSet    = "set"
+	ConfigOpAppend = "append"
+	ConfigOpRemove = "remove"
+)
+
+// ConfigChange is one edit inside a config transaction. Append and remove
+// only apply to list keys such as extensions and custom_apps; set replaces
+// the whole value, with list values separated by "|".
+type ConfigChange struct {
+	Key   string `json:"key"`
+	Op    string `json:"op"`
+	Value string `json:"value"`
+}
+
+type ConfigChangeResult struct {
+	Key     string `json:"key"`
+	Op      string `json:"op"`
+	Value   string `json:"value"`
+	OK      bool   `json:"ok"`
+	Changed bool   `json:"changed"`
+	Error   string `json:"error,omitempty"`
+}
+
+type ConfigTransactionResult struct {
+	Committed bool                 `json:"committed"`
+	Results   []ConfigChangeResult `json:"results"`
+	Error     string               `json:"error,omitempty"`
+}
+
+// booleanKeys only accept "0" or "1".
+var booleanKeys = []string{
+	"inject_css", "inject_theme_js", "replace_colors", "overwrite_assets",
+	"check_spicetify_update", "always_enable_devtools",
+	"disable_sentry", "disable_ui_logging", "remove_rtl_rule", "expose_apis",
+	"sidebar_config", "home_config", "experimental_features",
+}
+
+type configTransaction struct {
+	reason   string
+	changes  []ConfigChange
+	coalesce bool
+}
+
+func newConfigTransaction(reason string) *configTransaction {
+	return &configTransaction{reason: reason}
+}
+
+// Coalesce skips the history snapshot when the newest one was taken for the
+// same reason, so a run of automatic changes leaves a single undo point
+// instead of pushing the user's own snapshots out of the history.
+func (tx *configTransaction) Coalesce() *configTransaction {
+	tx.coalesce = true
+	return tx
+}
+
+func (tx *configTransaction) Set(key, value string) *configTransaction {
+	tx.changes = append(tx.changes, ConfigChange{Key: key, Op: ConfigOpSet, Value: value})
+	return tx
+}
+
+func (tx *configTransaction) Append(key, value string) *configTransaction {
+	tx.changes = append(tx.changes, ConfigChange{Key: key, Op: ConfigOpAppend, Value: value})
+	return tx
+}
+
+func (tx *configTransaction) Remove(key, value string) *configTransaction {
+	tx.changes = append(tx.changes, ConfigChange{Key: key, Op: ConfigOpRemove, Value: value})
+	return tx
+}
+
+// Commit validates every change, and only if all of them are valid writes
+// them with a single `spicetify config` invocation (or one direct file write
+// when the CLI is missing). The config is read and written under the
+// spicetify lock, so no other command can slip in between.
+func (tx *configTransaction) Commit() (ConfigTransactionResult, error) {
+	result := ConfigTransactionResult{Results: []ConfigChangeResult{}}
+
+	validated := false
+	err := helpers.UpdateSpicetifyConfig(func(current *spiceconfig.File) ([]string, error) {
+		next := spiceconfig.Parse(current.Bytes())
+
+		valid := true
+		for _, change := range tx.changes {
+			res := ConfigChangeResult{Key: change.Key, Op: change.Op, Value: change.Value}
+			if err := applyConfigChange(next, change); err != nil {
+				res.Error = err.Error()
+				valid = false
+			} else {
+				res.OK = true
+			}
+			result.Results = append(result.Results, res)
+		}
+		if !valid {
+			return nil, fmt.Errorf("config transaction rejected, nothing was written")
+		}
+		validated = true
+
+		for i, change := range tx.changes {
+			_, before, _ := current.Lookup(change.Key)
+			_, after, _ := next.Lookup(change.Key)
+			result.Results[i].Changed = before != after
+		}
+		args := configTransactionArgs(current, next)
+		if len(args) > 0 && !(tx.coalesce && latestSnapshotReason() == tx.reason) {
+			snapshotConfig(tx.reason)
+		}
+		return args, nil
+	})
+	if err != nil {
+		result.Error = err.Error()
+		for i := range result.Results {
+			result.Results[i].OK = false
+			if validated {
+				result.Results[i].Error = err.Error()
+			}
+		}
+		return result, err
+	}
+
+	result.Committed = true
+	return result, nil
+}
+
+func applyConfigChange(cfg *spiceconfig.File, change ConfigChange) error {
+	if _, ok := cfg.KeySection(change.Key); !ok {
+		return fmt.Errorf("unknown config key %q", change.Key)
+	}
+
+	isList := spiceconfig.IsListKey(change.Key)
+	switch change.Op {
+	case ConfigOpSet:
+		if slices.Contains(booleanKeys, change.Key) && change.Value != "0" && change.Value != "1" {
+			return fmt.Errorf("%s only accepts 0 or 1, got %q", change.Key, change.Value)
+		}
+		if isList {
+			return cfg.SetList(change.Key, spiceconfig.SplitList(change.Value))
+		}
+		return cfg.SetValue(change.Key, change.Value)
+
+	case ConfigOpAppend, ConfigOpRemove:
+		if !isList {
+			return fmt.Errorf("%s is not a list key and does not support %s", change.Key, change.Op)
+		}
+		entry := strings.TrimSpace(change.Value)
+		if entry == "" {
+			return fmt.Errorf("%s on %s needs a value", change.Op, change.Key)
+		}
+		if strings.Contains(entry, spiceconfig.ListSeparator) {
+			return fmt.Errorf("list entry %q must not contain %q", entry, spiceconfig.ListSeparator)
+		}
+		list := cfg.List(change.Key)
+		if change.Op == ConfigOpAppend {
+			if !slices.Contains(list, entry) {
+				list = append(list, entry)
+			}
+		} else {
+			list = slices.DeleteFunc(list, func(v string) bool { return v == entry })
+		}
+		return cfg.SetList(change.Key, list)
+	}
+
+	return fmt.Errorf("unknown config operation %q", change.Op)
+}
+
+// configTransactionArgs turns the difference between two configs into
+// `spicetify config` arguments. The CLI can only append to or remove from
+// list keys, so a changed list is cleared entry by entry and rebuilt in its
+// final order within the same invocation.
+func configTransactionArgs(current, next *spiceconfig.File) []string {
+	var args []string
+	seen := map[string]bool{}
+	for _, section := range next.Sections() {
+		for _, key := range section.Keys() {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			_, before, _ := current.Lookup(key)
+			_, after, _ := next.Lookup(key)
+			if before == after {
+				continue
+			}
+			if !spiceconfig.IsListKey(key) {
+				args = append(args, key, after)
+				continue
+			}
+
+			beforeList, afterList := spiceconfig.SplitList(before), spiceconfig.SplitList(after)
+			if isPrefix(beforeList, afterList) {
+				// Pure appends keep existing entries where they are.
+				for _, v := range afterList[len(beforeList):] {
+					args = append(args, key, v)
+				}
+				continue
+			}
+			if removed := slices.DeleteFunc(slices.Clone(beforeList), func(v string) bool { return slices.Contains(afterList, v) }); len(removed) > 0 && isSubsequence(afterList, beforeList) {
+				// Pure removals keep the remaining order intact.
+				for _, v := range removed {
+					args = append(args, key, v+"-")
+				}
+				continue
+			}
+			for _, v := range beforeList {
+				args = append(args, key, v+"-")
+			}
+			for _, v := range afterList {
+				args = append(args, key, v)
+			}
+		}
+	}
+	return args
+}
+
+func isPrefix(prefix, list []string) bool {
+	return len(prefix) <= len(list) && slices.Equal(prefix, list[:len(prefix)])
+}
+
+// isSubsequence reports whether sub appears in list in the same order.
+func isSubsequence(sub, list []string) bool {
+	i := 0
+	for _, v := range list {
+		if i < len(sub) && sub[i] == v {
+			i++
+		}
+	}
+	return i == len(sub)
+}
+
+// CommitConfigTransaction applies a batch of config changes atomically: if
+// any change is invalid nothing is written.
+func (a *App) CommitConfigTransaction(reason string, changes []ConfigChange) ConfigTransactionResult {
+	if reason == "" {
+		reason = "Update Spicetify config"
+	}
+	tx := newConfigTransaction(reason)
+	tx.changes = changes
+	result, _ := tx.Commit()
+	return result
+}
+
+// SetSpicetifyExtensionsEnabled toggles several extensions in one go. Keys
+// are extension file names, values the desired enabled state.
+func (a *App) SetSpicetifyExtensionsEnabled(states map[string]bool) ConfigTransactionResult {
+	tx := newConfigTransaction("Toggle extensions")
+	for _, file := range slices.Sorted(maps.Keys(states)) {
+		if states[file] {
+			tx.Append("extensions", file)
+		} else {
+			tx.Remove("extensions", file)
+		}
+	}
+	result, _ := tx.Commit()
+	return result
+}
+
+// SetSpicetifyAppsEnabled toggles several custom apps in one go.
+func (a *App) SetSpicetifyAppsEnabled(states map[string]bool) ConfigTransactionResult {
+	tx := newConfigTransaction("Toggle custom apps")
+	for _, appID := range slices.Sorted(maps.Keys(states)) {
+		if states[appID] {
+			tx.Append("custom_apps", appID)
+		} else {
+			tx.Remove("custom_apps", appID)
+		}
+	}
+	result, _ := tx.Commit()
+	return result
+}
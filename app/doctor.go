@@ -0,0 +1,321 @@
+package app
+
+import (
+	"log"
+	"manager/internal/helpers"
+	"manager/internal/spiceconfig"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// DoctorIssue describes a single inconsistency between config-xpui.ini and
+// the Extensions, CustomApps and Themes folders on disk. ID is stable across
+// scans so the frontend can hand a selection back to ApplyDoctorFixes.
+type DoctorIssue struct {
+	ID      string `json:"id"`
+	Kind    string `json:"kind"`
+	Target  string `json:"target"`
+	Problem string `json:"problem"`
+	Fix     string `json:"fix"`
+}
+
+type DoctorResult struct {
+	Applied []string          `json:"applied"`
+	Failed  map[string]string `json:"failed,omitempty"`
+}
+
+// doctorFix is the concrete action behind a DoctorIssue. Config changes are
+// collected as `spicetify config` key/value pairs so a whole batch can be
+// committed with a single CLI invocation.
+type doctorFix struct {
+	issue         DoctorIssue
+	configArgs    []string
+	removePaths   []string
+	removeIfEmpty []string
+}
+
+func (a *App) RunDoctor() []DoctorIssue {
+	issues := []DoctorIssue{}
+	for _, fix := range collectDoctorFixes(helpers.ReadSpicetifyConfig()) {
+		issues = append(issues, fix.issue)
+	}
+	return issues
+}
+
+// ApplyDoctorFixes re-runs the scan and applies the fixes whose IDs are in
+// ids. Issues that no longer exist are silently ignored. The scan and the
+// config edit share the spicetify lock, so a fix cannot undo a config
+// change made after the issues were listed.
+func (a *App) ApplyDoctorFixes(ids []string) DoctorResult {
+	result := DoctorResult{Applied: []string{}, Failed: map[string]string{}}
+
+	var selected []doctorFix
+	configErr := helpers.UpdateSpicetifyConfig(func(config *spiceconfig.File) ([]string, error) {
+		var configArgs []string
+		for _, fix := range collectDoctorFixes(config) {
+			if slices.Contains(ids, fix.issue.ID) {
+				selected = append(selected, fix)
+				configArgs = append(configArgs, fix.configArgs...)
+			}
+		}
+		if len(configArgs) > 0 {
+			snapshotConfig("Apply doctor fixes")
+		}
+		return configArgs, nil
+	})
+
+	for _, fix := range selected {
+		if len(fix.configArgs) > 0 && configErr != nil {
+			result.Failed[fix.issue.ID] = configErr.Error()
+			continue
+		}
+
+		failed := false
+		for _, p := range fix.removePaths {
+			if err := os.RemoveAll(p); err != nil {
+				result.Failed[fix.issue.ID] = err.Error()
+				failed = true
+				break
+			}
+		}
+		if failed {
+			continue
+		}
+		for _, dir := range fix.removeIfEmpty {
+			// os.Remove refuses non-empty directories, which is exactly what we want.
+			_ = os.Remove(dir)
+		}
+
+		log.Printf("[Doctor] Applied fix %s\n", fix.issue.ID)
+		result.Applied = append(result.Applied, fix.issue.ID)
+	}
+
+	return result
+}
+
+func collectDoctorFixes(config *spiceconfig.File) []doctorFix {
+	var fixes []doctorFix
+
+	fixes = append(fixes, doctorCheckExtensions(config)...)
+	fixes = append(fixes, doctorCheckApps(config)...)
+	fixes = append(fixes, doctorCheckTheme(config)...)
+	fixes = append(fixes, doctorCheckOrphanedMeta()...)
+
+	return fixes
+}
+
+func doctorCheckExtensions(config *spiceconfig.File) []doctorFix {
+	var fixes []doctorFix
+	for _, ext := range config.Extensions() {
+		if resolveExtensionPath(ext) != "" {
+			continue
+		}
+		fixes = append(fixes, doctorFix{
+			issue: DoctorIssue{
+				ID:      "missing-extension:" + ext,
+				Kind:    "extension",
+				Target:  ext,
+				Problem: "Extension " + ext + " is enabled in config-xpui.ini but its file does not exist",
+				Fix:     "Remove " + ext + " from the extensions list",
+			},
+			configArgs: []string{"extensions", ext + "-"},
+		})
+	}
+	return fixes
+}
+
+func doctorCheckApps(config *spiceconfig.File) []doctorFix {
+	var fixes []doctorFix
+	for _, appID := range config.CustomApps() {
+		if resolveCustomAppDir(appID) != "" {
+			continue
+		}
+		fixes = append(fixes, doctorFix{
+			issue: DoctorIssue{
+				ID:      "missing-app:" + appID,
+				Kind:    "app",
+				Target:  appID,
+				Problem: "Custom app " + appID + " is enabled in config-xpui.ini but its folder does not exist",
+				Fix:     "Remove " + appID + " from the custom_apps list",
+			},
+			configArgs: []string{"custom_apps", appID + "-"},
+		})
+	}
+	return fixes
+}
+
+func doctorCheckTheme(config *spiceconfig.File) []doctorFix {
+	currentTheme := config.CurrentTheme()
+	currentScheme := config.ColorScheme()
+	if currentTheme == "" {
+		return nil
+	}
+
+	themeDir := resolveThemeDir(currentTheme)
+	if themeDir == "" {
+		fallbackTheme, fallbackScheme := "", ""
+		if dir := resolveThemeDir("SpicetifyX"); dir != "" && currentTheme != "SpicetifyX" {
+			fallbackTheme = "SpicetifyX"
+			fallbackScheme = firstColorScheme(dir)
+		}
+
+		fix := "Clear current_theme and color_scheme"
+		if fallbackTheme != "" {
+			fix = "Switch to the bundled SpicetifyX theme"
+		}
+		return []doctorFix{{
+			issue: DoctorIssue{
+				ID:      "missing-theme:" + currentTheme,
+				Kind:    "theme",
+				Target:  currentTheme,
+				Problem: "current_theme points to " + currentTheme + " but the theme folder does not exist",
+				Fix:     fix,
+			},
+			configArgs: []string{"current_theme", fallbackTheme, "color_scheme", fallbackScheme},
+		}}
+	}
+
+	schemes := colorSchemeNames(themeDir)
+	if currentScheme == "" || len(schemes) == 0 || slices.Contains(schemes, currentScheme) {
+		return nil
+	}
+
+	return []doctorFix{{
+		issue: DoctorIssue{
+			ID:      "missing-scheme:" + currentTheme + "/" + currentScheme,
+			Kind:    "theme",
+			Target:  currentTheme,
+			Problem: "color_scheme " + currentScheme + " does not exist in " + currentTheme + "'s color.ini",
+			Fix:     "Switch to the " + schemes[0] + " color scheme",
+		},
+		configArgs: []string{"color_scheme", schemes[0]},
+	}}
+}
+
+func doctorCheckOrphanedMeta() []doctorFix {
+	var fixes []doctorFix
+
+	extDir := helpers.GetExtensionsDir()
+	if entries, err := os.ReadDir(extDir); err == nil {
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !strings.HasSuffix(name, ".meta.json") {
+				continue
+			}
+			addonFile := strings.TrimSuffix(name, ".meta.json")
+			if fileExists(filepath.Join(extDir, addonFile)) {
+				continue
+			}
+			fixes = append(fixes, doctorFix{
+				issue: DoctorIssue{
+					ID:      "orphaned-meta:extension:" + addonFile,
+					Kind:    "meta",
+					Target:  name,
+					Problem: "Metadata file " + name + " has no matching extension file",
+					Fix:     "Delete " + name,
+				},
+				removePaths: []string{filepath.Join(extDir, name)},
+			})
+		}
+	}
+
+	themesDir := helpers.GetThemesDir()
+	if entries, err := os.ReadDir(themesDir); err == nil {
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			dir := filepath.Join(themesDir, entry.Name())
+			metaPath := filepath.Join(dir, "theme.meta.json")
+			if !fileExists(metaPath) || fileExists(filepath.Join(dir, "user.css")) || fileExists(filepath.Join(dir, "color.ini")) {
+				continue
+			}
+			fixes = append(fixes, doctorFix{
+				issue: DoctorIssue{
+					ID:      "orphaned-meta:theme:" + entry.Name(),
+					Kind:    "meta",
+					Target:  entry.Name(),
+					Problem: "Theme folder " + entry.Name() + " only contains metadata, its user.css and color.ini are missing",
+					Fix:     "Delete the leftover theme.meta.json",
+				},
+				removePaths:   []string{metaPath},
+				removeIfEmpty: []string{dir},
+			})
+		}
+	}
+
+	appsDir := helpers.GetCustomAppsDir()
+	if entries, err := os.ReadDir(appsDir); err == nil {
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			dir := filepath.Join(appsDir, entry.Name())
+			metaPath := filepath.Join(dir, "app.meta.json")
+			if !fileExists(metaPath) || fileExists(filepath.Join(dir, "index.js")) {
+				continue
+			}
+			fixes = append(fixes, doctorFix{
+				issue: DoctorIssue{
+					ID:      "orphaned-meta:app:" + entry.Name(),
+					Kind:    "meta",
+					Target:  entry.Name(),
+					Problem: "Custom app folder " + entry.Name() + " only contains metadata, its index.js is missing",
+					Fix:     "Delete the leftover app.meta.json",
+				},
+				removePaths:   []string{metaPath},
+				removeIfEmpty: []string{dir},
+			})
+		}
+	}
+
+	sort.SliceStable(fixes, func(i, j int) bool { return fixes[i].issue.ID < fixes[j].issue.ID })
+	return fixes
+}
+
+// spicetifyBundledDir returns a folder shipped next to the spicetify binary.
+// The CLI falls back to these when an addon is not in the user config dir.
+func spicetifyBundledDir(name string) string {
+	return filepath.Join(filepath.Dir(helpers.GetSpicetifyExec()), name)
+}
+
+func resolveExtensionPath(ext string) string {
+	if filepath.IsAbs(ext) {
+		if fileExists(ext) {
+			return ext
+		}
+		return ""
+	}
+	for _, dir := range []string{helpers.GetExtensionsDir(), spicetifyBundledDir("Extensions")} {
+		for _, name := range []string{ext, ext + ".js"} {
+			p := filepath.Join(dir, name)
+			if info, err := os.Stat(p); err == nil && !info.IsDir() {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+func resolveCustomAppDir(appID string) string {
+	for _, dir := range []string{helpers.GetCustomAppsDir(), spicetifyBundledDir("CustomApps")} {
+		p := filepath.Join(dir, appID)
+		if info, err := os.Stat(p); err == nil && info.IsDir() {
+			return p
+		}
+	}
+	return ""
+}
+
+func resolveThemeDir(themeID string) string {
+	for _, dir := range []string{helpers.GetThemesDir(), spicetifyBundledDir("Themes")} {
+		p := filepath.Join(dir, themeID)
+		if fileExists(filepath.Join(p, "user.css")) || fileExists(filepath.Join(p, "color.ini")) {
+			return p
+		}
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"fmt"
+	"manager/internal/helpers"
+	"manager/internal/spiceconfig"
+	"slices"
+)
+
+// GetExtensionOrder returns the enabled extensions in the order Spicetify
+// loads them.
+func (a *App) GetExtensionOrder() []string {
+	return helpers.ReadSpicetifyConfig().Extensions()
+}
+
+// SetExtensionOrder rewrites the extensions key in the given order. order
+// must contain exactly the currently enabled extensions: reordering never
+// enables or disables anything.
+func (a *App) SetExtensionOrder(order []string) ([]string, error) {
+	current := a.GetExtensionOrder()
+	if !sameEntries(current, order) {
+		return current, fmt.Errorf("new order must contain exactly the enabled extensions %v", current)
+	}
+	if slices.Equal(current, order) {
+		return current, nil
+	}
+
+	tx := newConfigTransaction("Reorder extensions").Set("extensions", spiceconfig.JoinList(order))
+	if _, err := tx.Commit(); err != nil {
+		return a.GetExtensionOrder(), err
+	}
+	return a.GetExtensionOrder(), nil
+}
+
+func (a *App) MoveExtensionUp(addonFileName string) ([]string, error) {
+	return a.MoveExtension(addonFileName, -1)
+}
+
+func (a *App) MoveExtensionDown(addonFileName string) ([]string, error) {
+	return a.MoveExtension(addonFileName, 1)
+}
+
+// MoveExtension shifts an enabled extension by delta positions, clamped to
+// the ends of the list.
+func (a *App) MoveExtension(addonFileName string, delta int) ([]string, error) {
+	order := a.GetExtensionOrder()
+	from := slices.Index(order, addonFileName)
+	if from < 0 {
+		return order, fmt.Errorf("extension %s is not enabled", addonFileName)
+	}
+
+	to := max(0, min(len(order)-1, from+delta))
+	if to == from {
+		return order, nil
+	}
+
+	order = slices.Delete(order, from, from+1)
+	order = slices.Insert(order, to, addonFileName)
+	return a.SetExtensionOrder(order)
+}
+
+// sameEntries reports whether a and b hold the same entries, ignoring order.
+func sameEntries(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sortedA, sortedB := slices.Sorted(slices.Values(a)), slices.Sorted(slices.Values(b))
+	return slices.Equal(sortedA, sortedB)
+}
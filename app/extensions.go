@@ -6,7 +6,6 @@ import (
 	"manager/internal/helpers"
 	"os"
 	"path/filepath"
-	"regexp"
 	"slices"
 	"strings"
 	"sync"
@@ -39,20 +38,7 @@ type addonMeta struct {
 }
 
 func (a *App) GetInstalledExtensions() []AddonInfo {
-	configPath := helpers.GetConfigFilePath()
-	var enabledExtensions []string
-
-	if data, err := os.ReadFile(configPath); err == nil {
-		re := regexp.MustCompile(`(?m)^extensions\s*=\s*(.*)$`)
-		if m := re.FindSubmatch(data); len(m) > 1 {
-			for _, ext := range strings.Split(strings.TrimSpace(string(m[1])), "|") {
-				ext = strings.TrimSpace(ext)
-				if ext != "" {
-					enabledExtensions = append(enabledExtensions, ext)
-				}
-			}
-		}
-	}
+	enabledExtensions := helpers.ReadSpicetifyConfig().Extensions()
 
 	extensionsDir := helpers.GetExtensionsDir()
 	addons := []AddonInfo{}
@@ -127,30 +113,26 @@ func (a *App) GetInstalledExtensions() []AddonInfo {
 }
 
 func (a *App) ToggleSpicetifyExtension(addonFileName string, enable bool) bool {
-	exec := helpers.GetSpicetifyExec()
-
 	if enable {
 		extDir := helpers.GetExtensionsDir()
 		_ = os.MkdirAll(extDir, 0755)
 	}
 
-	var args []string
+	var tx *configTransaction
 	if enable {
-		args = []string{"config", "extensions", addonFileName}
+		tx = newConfigTransaction("Enable extension "+addonFileName).Append("extensions", addonFileName)
 	} else {
-		args = []string{"config", "extensions", addonFileName + "-"}
+		tx = newConfigTransaction("Disable extension "+addonFileName).Remove("extensions", addonFileName)
 	}
 
-	if err := helpers.SpicetifyCommand(exec, args, nil); err != nil {
+	if _, err := tx.Commit(); err != nil {
 		return false
 	}
 	return true
 }
 
 func (a *App) DeleteSpicetifyExtension(addonFileName string) bool {
-	exec := helpers.GetSpicetifyExec()
-
-	_ = helpers.SpicetifyCommand(exec, []string{"config", "extensions", addonFileName + "-"}, nil)
+	_ = changeSpicetifyConfig("Delete extension "+addonFileName, []string{"extensions", addonFileName + "-"})
 
 	extPath := filepath.Join(helpers.GetExtensionsDir(), addonFileName)
 
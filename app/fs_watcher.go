@@ -0,0 +1,240 @@
+package app
+
+import (
+	"log"
+	"manager/internal/helpers"
+	"manager/internal/spiceconfig"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	wailsRuntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const (
+	EventExtensionsChanged = "extensions-changed"
+	EventThemesChanged     = "themes-changed"
+	EventAppsChanged       = "apps-changed"
+	EventConfigChanged     = "config-changed"
+)
+
+// watcherDebounce groups bursts of file events (an editor saving, the CLI
+// rewriting the config, a zip being extracted) into a single UI refresh.
+const watcherDebounce = 300 * time.Millisecond
+
+// FolderChangeEvent is the payload of every watcher event. For
+// config-changed the IDs are the config keys whose value changed.
+type FolderChangeEvent struct {
+	IDs []string `json:"ids"`
+}
+
+// spicetifyWatcher watches the Spicetify config dir and its addon folders.
+// fsnotify is not recursive, so every theme and custom app folder gets its
+// own watch and folders that do not exist yet are retried periodically.
+type spicetifyWatcher struct {
+	watcher *fsnotify.Watcher
+	emit    func(event string, ids []string)
+	stop    chan struct{}
+
+	mu      sync.Mutex
+	pending map[string][]string
+	timer   *time.Timer
+	config  *spiceconfig.File
+}
+
+func newSpicetifyWatcher(emit func(event string, ids []string)) (*spicetifyWatcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &spicetifyWatcher{
+		watcher: fw,
+		emit:    emit,
+		stop:    make(chan struct{}),
+		pending: map[string][]string{},
+		config:  helpers.ReadSpicetifyConfig(),
+	}
+	w.addWatches()
+	go w.loop()
+	return w, nil
+}
+
+func (w *spicetifyWatcher) Close() {
+	close(w.stop)
+	_ = w.watcher.Close()
+	w.mu.Lock()
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.mu.Unlock()
+}
+
+func (w *spicetifyWatcher) addWatches() {
+	watched := w.watcher.WatchList()
+	add := func(dir string) {
+		if slices.Contains(watched, dir) {
+			return
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return
+		}
+		if err := w.watcher.Add(dir); err != nil {
+			log.Printf("[Watcher] Could not watch %s: %v\n", dir, err)
+		}
+	}
+
+	add(helpers.GetSpicetifyConfigDir())
+	add(helpers.GetExtensionsDir())
+	for _, root := range []string{helpers.GetThemesDir(), helpers.GetCustomAppsDir()} {
+		add(root)
+		if entries, err := os.ReadDir(root); err == nil {
+			for _, entry := range entries {
+				if entry.IsDir() {
+					add(filepath.Join(root, entry.Name()))
+				}
+			}
+		}
+	}
+}
+
+func (w *spicetifyWatcher) loop() {
+	rescan := time.NewTicker(5 * time.Second)
+	defer rescan.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-rescan.C:
+			w.addWatches()
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					w.addWatches()
+				}
+			}
+			w.classify(event.Name)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("[Watcher] Error: %v\n", err)
+		}
+	}
+}
+
+// classify maps a changed path to the event it belongs to and the affected
+// addon ID, then (re)arms the debounce timer.
+func (w *spicetifyWatcher) classify(path string) {
+	rel, err := filepath.Rel(helpers.GetSpicetifyConfigDir(), path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+
+	var event, id string
+	switch {
+	case len(parts) == 1 && parts[0] == "config-xpui.ini":
+		event = EventConfigChanged
+	case len(parts) >= 2 && parts[0] == "Extensions":
+		event = EventExtensionsChanged
+		id = strings.TrimSuffix(parts[1], ".meta.json")
+	case len(parts) >= 2 && parts[0] == "Themes":
+		event, id = EventThemesChanged, parts[1]
+	case len(parts) >= 2 && parts[0] == "CustomApps":
+		event, id = EventAppsChanged, parts[1]
+	default:
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if id != "" && !slices.Contains(w.pending[event], id) {
+		w.pending[event] = append(w.pending[event], id)
+	} else if _, ok := w.pending[event]; !ok {
+		w.pending[event] = []string{}
+	}
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.timer = time.AfterFunc(watcherDebounce, w.flush)
+}
+
+func (w *spicetifyWatcher) flush() {
+	w.mu.Lock()
+	pending := w.pending
+	w.pending = map[string][]string{}
+	w.mu.Unlock()
+
+	for event, ids := range pending {
+		if event == EventConfigChanged {
+			ids = w.changedConfigKeys()
+			if len(ids) == 0 {
+				continue
+			}
+		}
+		sort.Strings(ids)
+		w.emit(event, ids)
+	}
+}
+
+// changedConfigKeys diffs config-xpui.ini against the last version the
+// watcher saw, so a rewrite that changed nothing is not reported.
+func (w *spicetifyWatcher) changedConfigKeys() []string {
+	next := helpers.ReadSpicetifyConfig()
+
+	w.mu.Lock()
+	prev := w.config
+	w.config = next
+	w.mu.Unlock()
+
+	var keys []string
+	for _, cfg := range []*spiceconfig.File{prev, next} {
+		for _, section := range cfg.Sections() {
+			for _, key := range section.Keys() {
+				if slices.Contains(keys, key) {
+					continue
+				}
+				_, before, _ := prev.Lookup(key)
+				_, after, _ := next.Lookup(key)
+				if before != after {
+					keys = append(keys, key)
+				}
+			}
+		}
+	}
+	return keys
+}
+
+func (a *App) startFolderWatcher() {
+	if a.watcher != nil {
+		return
+	}
+	w, err := newSpicetifyWatcher(func(event string, ids []string) {
+		log.Printf("[Watcher] %s: %v\n", event, ids)
+		wailsRuntime.EventsEmit(a.ctx, event, FolderChangeEvent{IDs: ids})
+		if event == EventThemesChanged {
+			a.hotReloadThemeCSS(ids)
+		}
+	})
+	if err != nil {
+		log.Printf("[Watcher] Could not start: %v\n", err)
+		return
+	}
+	a.watcher = w
+}
+
+func (a *App) stopFolderWatcher() {
+	if a.watcher != nil {
+		a.watcher.Close()
+		a.watcher = nil
+	}
+}
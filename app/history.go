@@ -0,0 +1,265 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"manager/internal/helpers"
+	"manager/internal/spiceconfig"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxConfigSnapshots bounds ~/.spicetifyx/history; the oldest snapshots are
+// pruned first.
+const maxConfigSnapshots = 50
+
+// ConfigSnapshot is a copy of config-xpui.ini taken right before the manager
+// changed it. Changes describes what the manager did afterwards, i.e. what
+// restoring this snapshot would undo.
+type ConfigSnapshot struct {
+	ID          string   `json:"id"`
+	CreatedAt   string   `json:"createdAt"`
+	Reason      string   `json:"reason"`
+	Theme       string   `json:"theme"`
+	ColorScheme string   `json:"colorScheme"`
+	Extensions  []string `json:"extensions"`
+	CustomApps  []string `json:"customApps"`
+	Changes     []string `json:"changes,omitempty"`
+}
+
+// changeSpicetifyConfig snapshots the config and then runs `spicetify config`
+// with args. Every manager-initiated config edit should go through here so it
+// can be undone from the history view.
+func changeSpicetifyConfig(reason string, args []string) error {
+	snapshotConfig(reason)
+	return helpers.SpicetifyConfig(args)
+}
+
+// snapshotConfig records the current config-xpui.ini. Failures are logged
+// rather than returned: losing a history entry must never block the change
+// the user asked for.
+func snapshotConfig(reason string) {
+	data, err := os.ReadFile(helpers.GetConfigFilePath())
+	if err != nil {
+		return
+	}
+
+	historyDir := helpers.GetHistoryDir()
+	if err := os.MkdirAll(historyDir, 0755); err != nil {
+		log.Printf("[History] Could not create history dir: %v\n", err)
+		return
+	}
+
+	ids := snapshotIDs()
+	if len(ids) > 0 {
+		if latest, err := os.ReadFile(filepath.Join(historyDir, ids[len(ids)-1]+".ini")); err == nil && bytes.Equal(latest, data) {
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	id := now.Format("20060102T150405.000000Z")
+	for fileExists(filepath.Join(historyDir, id+".ini")) {
+		now = now.Add(time.Microsecond)
+		id = now.Format("20060102T150405.000000Z")
+	}
+	cfg := spiceconfig.Parse(data)
+	snapshot := ConfigSnapshot{
+		ID:          id,
+		CreatedAt:   now.Format(time.RFC3339),
+		Reason:      reason,
+		Theme:       cfg.CurrentTheme(),
+		ColorScheme: cfg.ColorScheme(),
+		Extensions:  cfg.Extensions(),
+		CustomApps:  cfg.CustomApps(),
+	}
+	meta, _ := json.MarshalIndent(snapshot, "", "  ")
+
+	if err := os.WriteFile(filepath.Join(historyDir, id+".ini"), data, 0644); err != nil {
+		log.Printf("[History] Could not write snapshot: %v\n", err)
+		return
+	}
+	_ = os.WriteFile(filepath.Join(historyDir, id+".json"), meta, 0644)
+
+	ids = append(ids, id)
+	for len(ids) > maxConfigSnapshots {
+		_ = os.Remove(filepath.Join(historyDir, ids[0]+".ini"))
+		_ = os.Remove(filepath.Join(historyDir, ids[0]+".json"))
+		ids = ids[1:]
+	}
+}
+
+// latestSnapshotReason returns why the newest snapshot was taken.
+func latestSnapshotReason() string {
+	ids := snapshotIDs()
+	if len(ids) == 0 {
+		return ""
+	}
+	var snapshot ConfigSnapshot
+	if meta, err := os.ReadFile(filepath.Join(helpers.GetHistoryDir(), ids[len(ids)-1]+".json")); err == nil {
+		_ = json.Unmarshal(meta, &snapshot)
+	}
+	return snapshot.Reason
+}
+
+// snapshotIDs returns the IDs of all stored snapshots, oldest first.
+func snapshotIDs() []string {
+	entries, err := os.ReadDir(helpers.GetHistoryDir())
+	if err != nil {
+		return nil
+	}
+	var ids []string
+	for _, entry := range entries {
+		if id, ok := strings.CutSuffix(entry.Name(), ".ini"); ok && !entry.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// GetConfigHistory lists snapshots newest first.
+func (a *App) GetConfigHistory() []ConfigSnapshot {
+	historyDir := helpers.GetHistoryDir()
+	ids := snapshotIDs()
+	snapshots := []ConfigSnapshot{}
+
+	// Each snapshot is diffed against the state that followed it: the next
+	// snapshot, or the live config for the newest one.
+	next, _ := os.ReadFile(helpers.GetConfigFilePath())
+	for i := len(ids) - 1; i >= 0; i-- {
+		id := ids[i]
+		data, err := os.ReadFile(filepath.Join(historyDir, id+".ini"))
+		if err != nil {
+			continue
+		}
+
+		var snapshot ConfigSnapshot
+		if meta, err := os.ReadFile(filepath.Join(historyDir, id+".json")); err == nil {
+			_ = json.Unmarshal(meta, &snapshot)
+		}
+		snapshot.ID = id
+		snapshot.Changes = diffConfigs(spiceconfig.Parse(data), spiceconfig.Parse(next))
+
+		snapshots = append(snapshots, snapshot)
+		next = data
+	}
+	return snapshots
+}
+
+// machineConfigKeys describe this install rather than the user's choices
+// and are never taken from a snapshot, nor is the [Backup] section.
+var machineConfigKeys = []string{"spotify_path", "prefs_path"}
+
+// RestoreConfigSnapshot restores the user-editable part of a snapshot into
+// config-xpui.ini and runs `spicetify apply`. The current config is
+// snapshotted first so the restore itself can be undone.
+func (a *App) RestoreConfigSnapshot(id string) error {
+	if !slices.Contains(snapshotIDs(), id) {
+		return fmt.Errorf("snapshot %s does not exist", id)
+	}
+	snapshot, err := spiceconfig.Load(filepath.Join(helpers.GetHistoryDir(), id+".ini"))
+	if err != nil {
+		return err
+	}
+
+	err = helpers.WithSpicetifyLock(func() error {
+		snapshotConfig("Before restoring snapshot " + id)
+		cfg := helpers.ReadSpicetifyConfig()
+		restoreUserConfig(cfg, snapshot)
+		return cfg.Save(helpers.GetConfigFilePath())
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[History] Restored snapshot %s, applying\n", id)
+	return helpers.SpicetifyCommand(helpers.GetSpicetifyExec(), []string{"apply"}, nil)
+}
+
+// restoreUserConfig makes every user-editable key of cfg match snapshot,
+// leaving the [Backup] section and machineConfigKeys as they are.
+func restoreUserConfig(cfg, snapshot *spiceconfig.File) {
+	restorable := func(section, key string) bool {
+		return section != spiceconfig.SectionBackup && !slices.Contains(machineConfigKeys, key)
+	}
+	for _, section := range cfg.Sections() {
+		for _, key := range section.Keys() {
+			if _, ok := snapshot.Get(section.Name(), key); !ok && restorable(section.Name(), key) {
+				section.Delete(key)
+			}
+		}
+	}
+	for _, section := range snapshot.Sections() {
+		for _, key := range section.Keys() {
+			if value, _ := section.Get(key); restorable(section.Name(), key) {
+				cfg.Set(section.Name(), key, value)
+			}
+		}
+	}
+}
+
+// diffConfigs describes how from turns into to, one line per changed key.
+// List keys are reported entry by entry so toggles read naturally.
+func diffConfigs(from, to *spiceconfig.File) []string {
+	var changes []string
+
+	seen := map[string]bool{}
+	var keys []string
+	for _, cfg := range []*spiceconfig.File{from, to} {
+		for _, section := range cfg.Sections() {
+			for _, key := range section.Keys() {
+				if !seen[key] {
+					seen[key] = true
+					keys = append(keys, key)
+				}
+			}
+		}
+	}
+
+	for _, key := range keys {
+		_, before, hadBefore := from.Lookup(key)
+		_, after, hasAfter := to.Lookup(key)
+
+		switch {
+		case !hadBefore:
+			changes = append(changes, fmt.Sprintf("%s: added (%s)", key, after))
+		case !hasAfter:
+			changes = append(changes, fmt.Sprintf("%s: removed (was %s)", key, before))
+		case spiceconfig.IsListKey(key):
+			beforeList, afterList := spiceconfig.SplitList(before), spiceconfig.SplitList(after)
+			changed := false
+			for _, v := range afterList {
+				if !slices.Contains(beforeList, v) {
+					changes = append(changes, fmt.Sprintf("%s: enabled %s", key, v))
+					changed = true
+				}
+			}
+			for _, v := range beforeList {
+				if !slices.Contains(afterList, v) {
+					changes = append(changes, fmt.Sprintf("%s: disabled %s", key, v))
+					changed = true
+				}
+			}
+			if !changed && !slices.Equal(beforeList, afterList) {
+				changes = append(changes, fmt.Sprintf("%s: reordered", key))
+			}
+		case before != after:
+			changes = append(changes, fmt.Sprintf("%s: %s → %s", key, displayValue(before), displayValue(after)))
+		}
+	}
+	return changes
+}
+
+func displayValue(v string) string {
+	if v == "" {
+		return "(empty)"
+	}
+	return v
+}
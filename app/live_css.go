@@ -0,0 +1,93 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"manager/internal/helpers"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// liveSnippet is the extra CSS layer kept on top of the theme CSS until it
+// is cleared, so it survives theme CSS pushes.
+var liveSnippet struct {
+	mu  sync.Mutex
+	css string
+}
+
+// BroadcastLiveCSS replaces the theme stylesheet in Spotify with css. A
+// non-nil snippet replaces the snippet layer as well.
+func BroadcastLiveCSS(themeID, css string, snippet *string) {
+	broadcast(&cssUpdateMessage{
+		bridgeHeader: newBridgeHeader(msgCSSUpdate),
+		ThemeID:      themeID,
+		CSS:          &css,
+		Snippet:      snippet,
+	})
+}
+
+// PushThemeCSS sends a theme's user.css to Spotify, which swaps it in
+// without `spicetify apply` or a restart.
+func (a *App) PushThemeCSS(themeID string) error {
+	themeDir := resolveThemeDir(themeID)
+	if themeDir == "" {
+		return fmt.Errorf("theme %s is not installed", themeID)
+	}
+	css, err := os.ReadFile(filepath.Join(themeDir, "user.css"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	liveSnippet.mu.Lock()
+	snippet := liveSnippet.css
+	liveSnippet.mu.Unlock()
+
+	BroadcastLiveCSS(themeID, string(css), &snippet)
+	return nil
+}
+
+// SetLiveCSSSnippet layers extra CSS over the theme, e.g. to try out a
+// snippet before adding it to user.css.
+func (a *App) SetLiveCSSSnippet(css string) {
+	liveSnippet.mu.Lock()
+	liveSnippet.css = css
+	liveSnippet.mu.Unlock()
+
+	broadcast(&cssUpdateMessage{bridgeHeader: newBridgeHeader(msgCSSUpdate), Snippet: &css})
+}
+
+// ResetLiveCSS drops all pushed CSS and puts the applied user.css back.
+func (a *App) ResetLiveCSS() {
+	liveSnippet.mu.Lock()
+	liveSnippet.css = ""
+	liveSnippet.mu.Unlock()
+
+	broadcast(&cssUpdateMessage{bridgeHeader: newBridgeHeader(msgCSSUpdate), Reset: true})
+}
+
+// restoreAppliedCSS drops the pushed theme CSS so the applied user.css shows
+// again, keeping the snippet layer.
+func restoreAppliedCSS() {
+	broadcast(&cssUpdateMessage{bridgeHeader: newBridgeHeader(msgCSSUpdate), Reset: true})
+
+	liveSnippet.mu.Lock()
+	snippet := liveSnippet.css
+	liveSnippet.mu.Unlock()
+	if snippet != "" {
+		broadcast(&cssUpdateMessage{bridgeHeader: newBridgeHeader(msgCSSUpdate), Snippet: &snippet})
+	}
+}
+
+// hotReloadThemeCSS pushes the active theme's CSS when the folder watcher
+// reports a change inside it.
+func (a *App) hotReloadThemeCSS(changedThemes []string) {
+	activeTheme := helpers.ReadSpicetifyConfig().CurrentTheme()
+	if activeTheme == "" || !slices.Contains(changedThemes, activeTheme) || a.themePreviewActive() {
+		return
+	}
+	if err := a.PushThemeCSS(activeTheme); err != nil {
+		log.Printf("[LivePreview] Could not hot-reload %s: %v\n", activeTheme, err)
+	}
+}
@@ -0,0 +1,221 @@
+package app
+
+import (
+	"log"
+	"manager/internal/helpers"
+	"manager/internal/spicecolor"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// paletteSyncScheme is the color.ini section the desktop palette is written
+// to. It is owned by the sync: every update overwrites its colors.
+const paletteSyncScheme = "pywal"
+
+type PaletteSyncStatus struct {
+	Enabled  bool   `json:"enabled"`
+	Source   string `json:"source,omitempty"`
+	Theme    string `json:"theme,omitempty"`
+	Scheme   string `json:"scheme"`
+	LastSync string `json:"lastSync,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// paletteSync watches the pywal and wallust caches and mirrors whichever
+// changed last into the active theme. Like the Spicetify watcher it watches
+// the parent folders, since both tools replace colors.json rather than
+// rewrite it, and retries folders that do not exist yet.
+type paletteSync struct {
+	app     *App
+	watcher *fsnotify.Watcher
+	stop    chan struct{}
+
+	mu     sync.Mutex
+	timer  *time.Timer
+	status PaletteSyncStatus
+}
+
+func newPaletteSync(a *App) (*paletteSync, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	s := &paletteSync{
+		app:     a,
+		watcher: fw,
+		stop:    make(chan struct{}),
+		status:  PaletteSyncStatus{Enabled: true, Scheme: paletteSyncScheme},
+	}
+	s.addWatches()
+	go s.loop()
+	return s, nil
+}
+
+func (s *paletteSync) Close() {
+	close(s.stop)
+	_ = s.watcher.Close()
+	s.mu.Lock()
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.mu.Unlock()
+}
+
+func (s *paletteSync) addWatches() {
+	watched := s.watcher.WatchList()
+	for _, file := range helpers.GetDesktopPaletteFiles() {
+		dir := filepath.Dir(file)
+		if slices.Contains(watched, dir) {
+			continue
+		}
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			if err := s.watcher.Add(dir); err != nil {
+				log.Printf("[PaletteSync] Could not watch %s: %v\n", dir, err)
+			}
+		}
+	}
+}
+
+func (s *paletteSync) loop() {
+	rescan := time.NewTicker(5 * time.Second)
+	defer rescan.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-rescan.C:
+			s.addWatches()
+		case event, ok := <-s.watcher.Events:
+			if !ok {
+				return
+			}
+			if !slices.Contains(helpers.GetDesktopPaletteFiles(), event.Name) || event.Has(fsnotify.Remove) {
+				continue
+			}
+			s.mu.Lock()
+			if s.timer != nil {
+				s.timer.Stop()
+			}
+			source := event.Name
+			s.timer = time.AfterFunc(watcherDebounce, func() { s.sync(source, false) })
+			s.mu.Unlock()
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("[PaletteSync] Error: %v\n", err)
+		}
+	}
+}
+
+// latestPaletteFile returns the most recently written palette file.
+func latestPaletteFile() string {
+	latest, latestTime := "", time.Time{}
+	for _, file := range helpers.GetDesktopPaletteFiles() {
+		if info, err := os.Stat(file); err == nil && info.ModTime().After(latestTime) {
+			latest, latestTime = file, info.ModTime()
+		}
+	}
+	return latest
+}
+
+// sync writes the palette in source to the dedicated scheme of the active
+// theme. The colors are pushed to Spotify when that scheme is the active one;
+// activate makes it active first, which is what enabling the sync does.
+func (s *paletteSync) sync(source string, activate bool) {
+	err := s.app.syncDesktopPalette(source, activate)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Source = source
+	s.status.Theme = helpers.ReadSpicetifyConfig().CurrentTheme()
+	s.status.Error = ""
+	if err != nil {
+		log.Printf("[PaletteSync] Sync from %s failed: %v\n", source, err)
+		s.status.Error = err.Error()
+		return
+	}
+	s.status.LastSync = time.Now().Format(time.RFC3339)
+}
+
+func (a *App) syncDesktopPalette(source string, activate bool) error {
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	palette, _, err := spicecolor.Decode(spicecolor.FormatPywal, string(data))
+	if err != nil {
+		return err
+	}
+	scheme := spicecolor.ToScheme(palette, spicecolor.DefaultMapping(spicecolor.FormatPywal))
+
+	config := helpers.ReadSpicetifyConfig()
+	themeID := config.CurrentTheme()
+	colors := map[string]string{}
+	for key, c := range scheme {
+		colors[key] = c.Hex()
+	}
+	if err := a.upsertColorScheme(themeID, paletteSyncScheme, colors); err != nil {
+		return err
+	}
+	log.Printf("[PaletteSync] Synced %s into %s/%s\n", source, themeID, paletteSyncScheme)
+
+	if config.ColorScheme() != paletteSyncScheme {
+		if !activate {
+			return nil
+		}
+		if _, err := newConfigTransaction("Sync desktop palette").Set("color_scheme", paletteSyncScheme).Commit(); err != nil {
+			return err
+		}
+	}
+
+	BroadcastLiveColors(themeID, paletteSyncScheme, colors)
+	return nil
+}
+
+// startPaletteSync starts watching and syncs the current palette right away.
+// activate switches color_scheme to the synced scheme, which only happens
+// when the user turns the sync on, not on every launch.
+func (a *App) startPaletteSync(activate bool) {
+	a.servicesMu.Lock()
+	defer a.servicesMu.Unlock()
+	if a.paletteSync != nil {
+		return
+	}
+	s, err := newPaletteSync(a)
+	if err != nil {
+		log.Printf("[PaletteSync] Could not start: %v\n", err)
+		return
+	}
+	a.paletteSync = s
+	if source := latestPaletteFile(); source != "" {
+		go s.sync(source, activate)
+	}
+}
+
+func (a *App) stopPaletteSync() {
+	a.servicesMu.Lock()
+	defer a.servicesMu.Unlock()
+	if a.paletteSync != nil {
+		a.paletteSync.Close()
+		a.paletteSync = nil
+	}
+}
+
+func (a *App) GetPaletteSyncStatus() PaletteSyncStatus {
+	a.servicesMu.Lock()
+	s := a.paletteSync
+	a.servicesMu.Unlock()
+	if s == nil {
+		return PaletteSyncStatus{Scheme: paletteSyncScheme}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
@@ -0,0 +1,207 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"manager/internal/wshub"
+	"sync"
+	"time"
+
+	wailsRuntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const (
+	msgPlayback        = "playback"
+	msgPlaybackCommand = "playback_command"
+)
+
+// playbackCommandTimeout is how long a command waits for Spotify to
+// confirm it.
+const playbackCommandTimeout = 3 * time.Second
+
+type PlaybackTrack struct {
+	URI        string `json:"uri"`
+	Title      string `json:"title"`
+	Artist     string `json:"artist"`
+	Album      string `json:"album"`
+	ImageURL   string `json:"imageURL,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// PlaybackState is what Spotify last reported. ProgressMs was measured at
+// UpdatedAt; while IsPlaying it keeps advancing from there.
+type PlaybackState struct {
+	Available  bool           `json:"available"`
+	Client     string         `json:"client,omitempty"`
+	Track      *PlaybackTrack `json:"track,omitempty"`
+	IsPlaying  bool           `json:"isPlaying"`
+	ProgressMs int64          `json:"progressMs"`
+	Volume     float64        `json:"volume"`
+	Liked      bool           `json:"liked"`
+	Shuffle    bool           `json:"shuffle"`
+	Repeat     int            `json:"repeat"`
+	UpdatedAt  time.Time      `json:"updatedAt"`
+}
+
+type playbackMessage struct {
+	bridgeHeader
+	PlaybackState
+}
+
+// PlaybackCommand is sent to Spotify. Command is one of play, pause,
+// toggle, next, previous, seek (PositionMs), volume (Volume, 0–1) and like
+// (Liked).
+type PlaybackCommand struct {
+	Command    string  `json:"command"`
+	PositionMs int64   `json:"positionMs"`
+	Volume     float64 `json:"volume"`
+	Liked      bool    `json:"liked"`
+}
+
+type playbackCommandMessage struct {
+	bridgeHeader
+	PlaybackCommand
+}
+
+var playback = struct {
+	mu          sync.Mutex
+	state       PlaybackState
+	client      *wshub.Client
+	nextID      int
+	subscribers map[int]func(PlaybackState)
+}{subscribers: make(map[int]func(PlaybackState))}
+
+// subscribePlayback calls fn with every playback update until the returned
+// function is called. fn runs on the bridge's read goroutine and must not
+// block.
+func subscribePlayback(fn func(PlaybackState)) (unsubscribe func()) {
+	playback.mu.Lock()
+	defer playback.mu.Unlock()
+	playback.nextID++
+	id := playback.nextID
+	playback.subscribers[id] = fn
+	return func() {
+		playback.mu.Lock()
+		delete(playback.subscribers, id)
+		playback.mu.Unlock()
+	}
+}
+
+func publishPlayback(state PlaybackState) {
+	playback.mu.Lock()
+	subscribers := make([]func(PlaybackState), 0, len(playback.subscribers))
+	for _, fn := range playback.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	playback.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(state)
+	}
+}
+
+// updatePlayback records a report from a client. The client that reported
+// last is the one commands go to.
+func updatePlayback(c *wshub.Client, clientID string, data []byte) {
+	var msg playbackMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return
+	}
+	state := msg.PlaybackState
+	state.Available = true
+	state.Client = clientID
+	state.UpdatedAt = time.Now()
+
+	playback.mu.Lock()
+	playback.state = state
+	playback.client = c
+	playback.mu.Unlock()
+
+	publishPlayback(state)
+}
+
+// clearPlayback forgets the state of a client that went away.
+func clearPlayback(c *wshub.Client) {
+	playback.mu.Lock()
+	if playback.client != c {
+		playback.mu.Unlock()
+		return
+	}
+	playback.client = nil
+	playback.state = PlaybackState{UpdatedAt: time.Now()}
+	state := playback.state
+	playback.mu.Unlock()
+
+	publishPlayback(state)
+}
+
+// watchPlayback forwards playback updates to the frontend as
+// "playback-state" events.
+func (a *App) watchPlayback() {
+	subscribePlayback(func(state PlaybackState) {
+		wailsRuntime.EventsEmit(a.ctx, "playback-state", state)
+	})
+}
+
+func (a *App) GetPlaybackState() PlaybackState {
+	playback.mu.Lock()
+	defer playback.mu.Unlock()
+	return playback.state
+}
+
+// SendPlaybackCommand runs cmd in Spotify and waits for it to confirm.
+func (a *App) SendPlaybackCommand(cmd PlaybackCommand) error {
+	switch cmd.Command {
+	case "play", "pause", "toggle", "next", "previous", "like":
+	case "seek":
+		if cmd.PositionMs < 0 {
+			return fmt.Errorf("invalid seek position %d", cmd.PositionMs)
+		}
+	case "volume":
+		if cmd.Volume < 0 || cmd.Volume > 1 {
+			return fmt.Errorf("volume must be between 0 and 1, got %g", cmd.Volume)
+		}
+	default:
+		return fmt.Errorf("unknown playback command %q", cmd.Command)
+	}
+
+	playback.mu.Lock()
+	client := playback.client
+	playback.mu.Unlock()
+	if client == nil {
+		return fmt.Errorf("no Spotify client is connected")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), playbackCommandTimeout)
+	defer cancel()
+	_, err := bridgeRequest(ctx, client, &playbackCommandMessage{
+		bridgeHeader:    newBridgeHeader(msgPlaybackCommand),
+		PlaybackCommand: cmd,
+	})
+	return err
+}
+
+func (a *App) PlaybackTogglePlay() error {
+	return a.SendPlaybackCommand(PlaybackCommand{Command: "toggle"})
+}
+
+func (a *App) PlaybackNext() error {
+	return a.SendPlaybackCommand(PlaybackCommand{Command: "next"})
+}
+
+func (a *App) PlaybackPrevious() error {
+	return a.SendPlaybackCommand(PlaybackCommand{Command: "previous"})
+}
+
+func (a *App) PlaybackSeek(positionMs int64) error {
+	return a.SendPlaybackCommand(PlaybackCommand{Command: "seek", PositionMs: positionMs})
+}
+
+func (a *App) PlaybackSetVolume(volume float64) error {
+	return a.SendPlaybackCommand(PlaybackCommand{Command: "volume", Volume: volume})
+}
+
+func (a *App) PlaybackSetLiked(liked bool) error {
+	return a.SendPlaybackCommand(PlaybackCommand{Command: "like", Liked: liked})
+}
@@ -0,0 +1,452 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"manager/internal/helpers"
+	"manager/internal/suntime"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	ScheduleRuleTime     = "time"
+	ScheduleRuleSun      = "sun"
+	ScheduleRuleRotation = "rotation"
+
+	SunEventSunrise = "sunrise"
+	SunEventSunset  = "sunset"
+)
+
+// schedulerTick is how often the scheduler checks for a new switch point.
+const schedulerTick = 30 * time.Second
+
+// scheduledApplyInterval spaces out the `spicetify apply` runs that make
+// scheduled switches stick; switches in between are live-only until the
+// next one.
+const scheduledApplyInterval = 10 * time.Minute
+
+// scheduledSwitchReason is the history reason of every scheduled switch, so
+// a run of them shares one snapshot.
+const scheduledSwitchReason = "Scheduled color switch"
+
+// ScheduleRule is one switch point. Time rules fire daily at At ("15:04"),
+// sun rules at sunrise or sunset plus OffsetMinutes, rotation rules every
+// IntervalMinutes, cycling through Schemes. Theme defaults to whichever
+// theme is active when the rule fires.
+type ScheduleRule struct {
+	ID              string   `json:"id"`
+	Kind            string   `json:"kind"`
+	Theme           string   `json:"theme,omitempty"`
+	Scheme          string   `json:"scheme,omitempty"`
+	At              string   `json:"at,omitempty"`
+	Event           string   `json:"event,omitempty"`
+	OffsetMinutes   int      `json:"offsetMinutes,omitempty"`
+	Schemes         []string `json:"schemes,omitempty"`
+	IntervalMinutes int      `json:"intervalMinutes,omitempty"`
+}
+
+// ColorSchedule is stored in ~/.spicetifyx/schedule.json. Whichever rule
+// fired most recently decides the scheme.
+type ColorSchedule struct {
+	Enabled   bool           `json:"enabled"`
+	Latitude  float64        `json:"latitude"`
+	Longitude float64        `json:"longitude"`
+	Rules     []ScheduleRule `json:"rules"`
+}
+
+type ScheduledSwitch struct {
+	RuleID string `json:"ruleId"`
+	Theme  string `json:"theme,omitempty"`
+	Scheme string `json:"scheme"`
+	At     string `json:"at"`
+}
+
+type ScheduleStatus struct {
+	Running bool             `json:"running"`
+	Current *ScheduledSwitch `json:"current,omitempty"`
+	Next    *ScheduledSwitch `json:"next,omitempty"`
+	Sunrise string           `json:"sunrise,omitempty"`
+	Sunset  string           `json:"sunset,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+func readColorSchedule() ColorSchedule {
+	schedule := ColorSchedule{Rules: []ScheduleRule{}}
+	data, err := os.ReadFile(helpers.GetSchedulePath())
+	if err != nil {
+		return schedule
+	}
+	if err := json.Unmarshal(data, &schedule); err != nil {
+		log.Printf("[Scheduler] Ignoring unreadable schedule: %v\n", err)
+		return ColorSchedule{Rules: []ScheduleRule{}}
+	}
+	if schedule.Rules == nil {
+		schedule.Rules = []ScheduleRule{}
+	}
+	return schedule
+}
+
+func writeColorSchedule(schedule ColorSchedule) error {
+	if err := os.MkdirAll(filepath.Dir(helpers.GetSchedulePath()), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(schedule, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(helpers.GetSchedulePath(), data, 0644)
+}
+
+func validateColorSchedule(schedule *ColorSchedule) error {
+	if schedule.Latitude < -90 || schedule.Latitude > 90 || schedule.Longitude < -180 || schedule.Longitude > 180 {
+		return fmt.Errorf("latitude must be within ±90 and longitude within ±180")
+	}
+
+	var ids []string
+	for i := range schedule.Rules {
+		rule := &schedule.Rules[i]
+		for n := i + 1; rule.ID == "" || slices.Contains(ids, rule.ID); n++ {
+			rule.ID = fmt.Sprintf("rule-%d", n)
+		}
+		ids = append(ids, rule.ID)
+
+		if rule.Theme != "" && resolveThemeDir(rule.Theme) == "" {
+			return fmt.Errorf("%s: theme %s is not installed", rule.ID, rule.Theme)
+		}
+		switch rule.Kind {
+		case ScheduleRuleTime:
+			if _, err := time.Parse("15:04", rule.At); err != nil {
+				return fmt.Errorf("%s: time %q must be HH:MM", rule.ID, rule.At)
+			}
+		case ScheduleRuleSun:
+			if rule.Event != SunEventSunrise && rule.Event != SunEventSunset {
+				return fmt.Errorf("%s: event must be sunrise or sunset", rule.ID)
+			}
+		case ScheduleRuleRotation:
+			if len(rule.Schemes) == 0 {
+				return fmt.Errorf("%s: a rotation needs at least one scheme", rule.ID)
+			}
+			if rule.IntervalMinutes < 1 {
+				return fmt.Errorf("%s: rotation interval must be at least one minute", rule.ID)
+			}
+			continue
+		default:
+			return fmt.Errorf("%s: unknown rule kind %q", rule.ID, rule.Kind)
+		}
+		if rule.Scheme == "" {
+			return fmt.Errorf("%s: scheme is required", rule.ID)
+		}
+	}
+	return nil
+}
+
+// occurrence returns when a time or sun rule fires on the given day.
+func (r ScheduleRule) occurrence(day time.Time, schedule ColorSchedule) (time.Time, bool) {
+	switch r.Kind {
+	case ScheduleRuleTime:
+		at, err := time.Parse("15:04", r.At)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Date(day.Year(), day.Month(), day.Day(), at.Hour(), at.Minute(), 0, 0, day.Location()), true
+	case ScheduleRuleSun:
+		sunrise, sunset, ok := suntime.Times(day, schedule.Latitude, schedule.Longitude)
+		if !ok {
+			return time.Time{}, false
+		}
+		t := sunrise
+		if r.Event == SunEventSunset {
+			t = sunset
+		}
+		return t.Add(time.Duration(r.OffsetMinutes) * time.Minute), true
+	}
+	return time.Time{}, false
+}
+
+// switchAround returns the latest switch of rule at or before now and the
+// first one after it.
+func (r ScheduleRule) switchAround(now time.Time, schedule ColorSchedule) (last, next *ScheduledSwitch, lastAt, nextAt time.Time) {
+	newSwitch := func(at time.Time, scheme string) *ScheduledSwitch {
+		return &ScheduledSwitch{RuleID: r.ID, Theme: r.Theme, Scheme: scheme, At: at.Format(time.RFC3339)}
+	}
+
+	if r.Kind == ScheduleRuleRotation {
+		period := time.Duration(r.IntervalMinutes) * time.Minute
+		start := now.Truncate(period)
+		index := int(start.Unix()/int64(period.Seconds())) % len(r.Schemes)
+		return newSwitch(start, r.Schemes[index]), newSwitch(start.Add(period), r.Schemes[(index+1)%len(r.Schemes)]),
+			start, start.Add(period)
+	}
+
+	for offset := -1; offset <= 1; offset++ {
+		at, ok := r.occurrence(now.AddDate(0, 0, offset), schedule)
+		if !ok {
+			continue
+		}
+		if !at.After(now) && (last == nil || at.After(lastAt)) {
+			last, lastAt = newSwitch(at, r.Scheme), at
+		}
+		if at.After(now) && (next == nil || at.Before(nextAt)) {
+			next, nextAt = newSwitch(at, r.Scheme), at
+		}
+	}
+	return last, next, lastAt, nextAt
+}
+
+// scheduleAt works out which switch is in effect at now and which comes
+// next, across all rules.
+func scheduleAt(schedule ColorSchedule, now time.Time) (current, next *ScheduledSwitch) {
+	var currentAt, nextAt time.Time
+	for _, rule := range schedule.Rules {
+		last, upcoming, lastAt, upcomingAt := rule.switchAround(now, schedule)
+		if last != nil && (current == nil || lastAt.After(currentAt)) {
+			current, currentAt = last, lastAt
+		}
+		if upcoming != nil && (next == nil || upcomingAt.Before(nextAt)) {
+			next, nextAt = upcoming, upcomingAt
+		}
+	}
+	return current, next
+}
+
+type colorScheduler struct {
+	app  *App
+	stop chan struct{}
+
+	mu         sync.Mutex
+	last       ScheduledSwitch
+	err        string
+	applyTimer *time.Timer
+	lastApply  time.Time
+}
+
+// readLastSwitch returns the switch the scheduler made last, in this or an
+// earlier run.
+func readLastSwitch() ScheduledSwitch {
+	var last ScheduledSwitch
+	data, err := os.ReadFile(helpers.GetScheduleStatePath())
+	if err != nil {
+		return last
+	}
+	if err := json.Unmarshal(data, &last); err != nil {
+		log.Printf("[Scheduler] Ignoring unreadable schedule state: %v\n", err)
+		return ScheduledSwitch{}
+	}
+	return last
+}
+
+func writeLastSwitch(last ScheduledSwitch) {
+	data, err := json.Marshal(last)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(helpers.GetScheduleStatePath()), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(helpers.GetScheduleStatePath(), data, 0644)
+	}
+	if err != nil {
+		log.Printf("[Scheduler] Could not save schedule state: %v\n", err)
+	}
+}
+
+func (s *colorScheduler) loop() {
+	ticker := time.NewTicker(schedulerTick)
+	defer ticker.Stop()
+	s.check()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.check()
+		}
+	}
+}
+
+// check switches only when a new switch point has been reached, so a scheme
+// picked by hand stays until the next rule fires. The last switch is kept
+// on disk, so starting the manager does not redo it either.
+func (s *colorScheduler) check() {
+	current, _ := scheduleAt(readColorSchedule(), time.Now())
+	if current == nil {
+		return
+	}
+	s.mu.Lock()
+	seen := s.last == *current
+	s.mu.Unlock()
+	if seen {
+		return
+	}
+
+	changed, err := s.app.applyScheduledSwitch(*current)
+	if err != nil {
+		// Not recorded as done, so the next tick tries again.
+		log.Printf("[Scheduler] Switch to %s failed: %v\n", current.Scheme, err)
+		s.mu.Lock()
+		s.err = err.Error()
+		s.mu.Unlock()
+		return
+	}
+	writeLastSwitch(*current)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last = *current
+	s.err = ""
+	if changed {
+		s.scheduleApplyLocked()
+	}
+}
+
+// scheduleApplyLocked runs `spicetify apply --no-restart` so the next
+// Spotify start has the scheme, at most once per scheduledApplyInterval.
+// Switches made while one is pending share it.
+func (s *colorScheduler) scheduleApplyLocked() {
+	if s.applyTimer != nil {
+		return
+	}
+	delay := time.Until(s.lastApply.Add(scheduledApplyInterval))
+	if delay < 0 {
+		delay = 0
+	}
+	s.applyTimer = time.AfterFunc(delay, s.runApply)
+}
+
+func (s *colorScheduler) runApply() {
+	s.mu.Lock()
+	s.applyTimer = nil
+	s.lastApply = time.Now()
+	s.mu.Unlock()
+
+	if err := helpers.SpicetifyCommand(helpers.GetSpicetifyExec(), []string{"apply", "--no-restart"}, nil); err != nil {
+		log.Printf("[Scheduler] Apply failed: %v\n", err)
+	}
+}
+
+// close stops the scheduler. A pending apply runs now rather than being
+// dropped, since the config already has the new scheme.
+func (s *colorScheduler) close() {
+	close(s.stop)
+	s.mu.Lock()
+	pending := s.applyTimer != nil && s.applyTimer.Stop()
+	s.mu.Unlock()
+	if pending {
+		s.runApply()
+	}
+}
+
+// applyScheduledSwitch changes the config and recolors a running Spotify
+// live. changed is false when the config already had the scheme. The caller
+// takes care of applying the config.
+func (a *App) applyScheduledSwitch(sw ScheduledSwitch) (changed bool, err error) {
+	config := helpers.ReadSpicetifyConfig()
+	themeID := sw.Theme
+	if themeID == "" {
+		themeID = config.CurrentTheme()
+	}
+	if config.CurrentTheme() == themeID && config.ColorScheme() == sw.Scheme {
+		return false, nil
+	}
+	if !slices.Contains(colorSchemeNames(resolveThemeDir(themeID)), sw.Scheme) {
+		return false, fmt.Errorf("scheme %s does not exist in %s", sw.Scheme, themeID)
+	}
+
+	log.Printf("[Scheduler] Rule %s: switching to %s/%s\n", sw.RuleID, themeID, sw.Scheme)
+	themeChanged := config.CurrentTheme() != themeID
+	tx := newConfigTransaction(scheduledSwitchReason).Coalesce()
+	if themeChanged {
+		tx.Set("current_theme", themeID)
+	}
+	if _, err := tx.Set("color_scheme", sw.Scheme).Commit(); err != nil {
+		return false, err
+	}
+
+	// A different theme brings its own CSS, which cannot be swapped live.
+	if !themeChanged {
+		BroadcastLiveColors(themeID, sw.Scheme, a.GetThemePresets(themeID)[sw.Scheme])
+	}
+	return true, nil
+}
+
+func (a *App) startScheduler() {
+	a.servicesMu.Lock()
+	defer a.servicesMu.Unlock()
+	a.startSchedulerLocked()
+}
+
+func (a *App) startSchedulerLocked() {
+	if a.scheduler != nil {
+		return
+	}
+	a.scheduler = &colorScheduler{app: a, stop: make(chan struct{}), last: readLastSwitch()}
+	go a.scheduler.loop()
+}
+
+func (a *App) stopScheduler() {
+	a.servicesMu.Lock()
+	defer a.servicesMu.Unlock()
+	a.stopSchedulerLocked()
+}
+
+func (a *App) stopSchedulerLocked() {
+	if a.scheduler != nil {
+		a.scheduler.close()
+		a.scheduler = nil
+	}
+}
+
+func (a *App) GetColorSchedule() ColorSchedule {
+	return readColorSchedule()
+}
+
+// UpdateColorSchedule validates and stores the schedule, then starts or
+// stops the scheduler to match.
+func (a *App) UpdateColorSchedule(schedule ColorSchedule) (ColorSchedule, error) {
+	if schedule.Rules == nil {
+		schedule.Rules = []ScheduleRule{}
+	}
+	if err := validateColorSchedule(&schedule); err != nil {
+		return readColorSchedule(), err
+	}
+	if err := writeColorSchedule(schedule); err != nil {
+		return readColorSchedule(), err
+	}
+
+	a.servicesMu.Lock()
+	defer a.servicesMu.Unlock()
+	a.stopSchedulerLocked()
+	if schedule.Enabled {
+		a.startSchedulerLocked()
+	}
+	return schedule, nil
+}
+
+func (a *App) GetScheduleStatus() ScheduleStatus {
+	schedule := readColorSchedule()
+	now := time.Now()
+	a.servicesMu.Lock()
+	scheduler := a.scheduler
+	a.servicesMu.Unlock()
+	status := ScheduleStatus{Running: scheduler != nil}
+	status.Current, status.Next = scheduleAt(schedule, now)
+
+	for _, rule := range schedule.Rules {
+		if rule.Kind == ScheduleRuleSun {
+			if sunrise, sunset, ok := suntime.Times(now, schedule.Latitude, schedule.Longitude); ok {
+				status.Sunrise = sunrise.Format(time.RFC3339)
+				status.Sunset = sunset.Format(time.RFC3339)
+			}
+			break
+		}
+	}
+	if scheduler != nil {
+		scheduler.mu.Lock()
+		status.Error = scheduler.err
+		scheduler.mu.Unlock()
+	}
+	return status
+}
@@ -3,6 +3,7 @@ package app
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"manager/internal/helpers"
 	"os"
@@ -13,12 +14,18 @@ type AppSettings struct {
 	DiscordRpc           bool `json:"discordRpc"`
 	CloseToTray          bool `json:"closeToTray"`
 	CheckUpdatesOnLaunch bool `json:"checkUpdatesOnLaunch"`
+	PaletteSync          bool `json:"paletteSync"`
+	BridgePortMin        int  `json:"bridgePortMin"`
+	BridgePortMax        int  `json:"bridgePortMax"`
 }
 
 var defaultSettings = AppSettings{
 	DiscordRpc:           true,
 	CloseToTray:          false,
 	CheckUpdatesOnLaunch: true,
+	PaletteSync:          false,
+	BridgePortMin:        3001,
+	BridgePortMax:        3010,
 }
 
 func ReadSettings() (AppSettings, error) {
@@ -34,6 +41,11 @@ func ReadSettings() (AppSettings, error) {
 	result.DiscordRpc = s.DiscordRpc
 	result.CloseToTray = s.CloseToTray
 	result.CheckUpdatesOnLaunch = s.CheckUpdatesOnLaunch
+	result.PaletteSync = s.PaletteSync
+	if validPortRange(s.BridgePortMin, s.BridgePortMax) {
+		result.BridgePortMin = s.BridgePortMin
+		result.BridgePortMax = s.BridgePortMax
+	}
 	return result, nil
 }
 
@@ -56,6 +68,24 @@ func (a *App) GetSettings() (AppSettings, error) {
 func (a *App) UpdateSettings(partial map[string]any) (AppSettings, error) {
 	current, _ := ReadSettings()
 
+	// The bridge port range takes effect the next time the manager starts. It is
+	// checked first so a bad range does not leave other settings half applied.
+	_, hasMin := partial["bridgePortMin"]
+	_, hasMax := partial["bridgePortMax"]
+	if hasMin || hasMax {
+		portMin, portMax := current.BridgePortMin, current.BridgePortMax
+		if v, ok := partial["bridgePortMin"]; ok {
+			portMin = toInt(v)
+		}
+		if v, ok := partial["bridgePortMax"]; ok {
+			portMax = toInt(v)
+		}
+		if !validPortRange(portMin, portMax) {
+			return current, fmt.Errorf("invalid bridge port range %d-%d", portMin, portMax)
+		}
+		current.BridgePortMin, current.BridgePortMax = portMin, portMax
+	}
+
 	if v, ok := partial["discordRpc"]; ok {
 		newVal := toBool(v)
 		current.DiscordRpc = newVal
@@ -72,6 +102,14 @@ func (a *App) UpdateSettings(partial map[string]any) (AppSettings, error) {
 	if v, ok := partial["checkUpdatesOnLaunch"]; ok {
 		current.CheckUpdatesOnLaunch = toBool(v)
 	}
+	if v, ok := partial["paletteSync"]; ok {
+		current.PaletteSync = toBool(v)
+		if current.PaletteSync {
+			a.startPaletteSync(true)
+		} else {
+			a.stopPaletteSync()
+		}
+	}
 
 	return current, WriteSettings(current)
 }
@@ -124,6 +162,20 @@ func toBool(v any) bool {
 	return false
 }
 
+func toInt(v any) int {
+	switch val := v.(type) {
+	case float64:
+		return int(val)
+	case int:
+		return val
+	}
+	return 0
+}
+
+func validPortRange(portMin, portMax int) bool {
+	return portMin >= 1024 && portMax <= 65535 && portMin <= portMax
+}
+
 func copyDirRecursive(src, dest string) error {
 	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
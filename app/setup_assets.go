@@ -1,6 +1,8 @@
 package app
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,15 +10,23 @@ import (
 	"manager/internal/helpers"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 )
 
+// Every preinstall entry must carry a checksum: the hex SHA-256 of its
+// downloaded payload (the raw files concatenated in order, or the archive for
+// apps). The download is verified against it before anything is written to
+// disk, and entries without one, or with URLs that follow a branch instead
+// of a commit, are refused. assets/pin_preinstall.go fills both in.
 type assetExtension struct {
 	Name           string         `json:"name"`
 	RawFiles       []string       `json:"raw_files"`
 	RawMetaURL     *string        `json:"raw_meta_url"`
 	RawMetaContent map[string]any `json:"raw_meta_content"`
 	FileName       string         `json:"file_name"`
+	Checksum       string         `json:"checksum,omitempty"`
 }
 
 type assetTheme struct {
@@ -24,6 +34,7 @@ type assetTheme struct {
 	RawFiles       []string       `json:"raw_files"`
 	RawMetaURL     *string        `json:"raw_meta_url"`
 	RawMetaContent map[string]any `json:"raw_meta_content"`
+	Checksum       string         `json:"checksum,omitempty"`
 }
 
 type assetApp struct {
@@ -31,131 +42,419 @@ type assetApp struct {
 	RawArchiveURL  string         `json:"raw_archive_url"`
 	RawMetaURL     *string        `json:"raw_meta_url"`
 	RawMetaContent map[string]any `json:"raw_meta_content"`
+	Checksum       string         `json:"checksum,omitempty"`
 }
 
 type assetsFile struct {
+	Version    int              `json:"version"`
 	Extensions []assetExtension `json:"extensions"`
 	Themes     []assetTheme     `json:"themes"`
 	Apps       []assetApp       `json:"apps"`
 }
 
+type PreinstallEntry struct {
+	Key         string `json:"key"`
+	Kind        string `json:"kind"`
+	Name        string `json:"name"`
+	Pack        string `json:"pack"`
+	PackVersion int    `json:"packVersion"`
+	Installed   bool   `json:"installed"`
+	UpToDate    bool   `json:"upToDate"`
+}
+
+// PreinstallOptions controls a preinstall run. Selection holds entry keys as
+// returned by GetPreinstallEntries; an empty selection installs everything.
+// PackPath optionally points to an additional user-provided pack whose
+// entries override bundled ones with the same key.
+type PreinstallOptions struct {
+	Selection []string `json:"selection,omitempty"`
+	PackPath  string   `json:"packPath,omitempty"`
+	Force     bool     `json:"force,omitempty"`
+}
+
+type PreinstallReport struct {
+	Installed []string          `json:"installed"`
+	Skipped   []string          `json:"skipped"`
+	Failed    map[string]string `json:"failed,omitempty"`
+}
+
+type preinstallRecord struct {
+	Pack        string   `json:"pack"`
+	PackVersion int      `json:"packVersion"`
+	Fingerprint string   `json:"fingerprint"`
+	Files       []string `json:"files"`
+	InstalledAt string   `json:"installedAt"`
+}
+
+type preinstallState struct {
+	Entries map[string]preinstallRecord `json:"entries"`
+}
+
+// preinstallItem is a single installable entry of a pack, flattened across
+// extensions, themes and apps.
+type preinstallItem struct {
+	key         string
+	kind        string
+	name        string
+	pack        string
+	packVersion int
+	fingerprint string
+	install     func() ([]string, error)
+}
+
 func (a *App) SetupSpicetifyAssets() error {
-	assetsData, err := assets.PreinstallAssetsJSON.ReadFile("preinstall.json")
+	report, err := a.InstallPreinstallPack(PreinstallOptions{})
+	if err != nil {
+		return err
+	}
+	for key, reason := range report.Failed {
+		fmt.Printf("[setup-assets] Warning: %s was not installed: %s\n", key, reason)
+	}
+	return nil
+}
+
+func (a *App) GetPreinstallEntries(packPath string) ([]PreinstallEntry, error) {
+	items, err := loadPreinstallItems(packPath)
 	if err != nil {
-		return fmt.Errorf("could not read assets.json: %w", err)
+		return nil, err
 	}
+	state := readPreinstallState()
 
-	var assets assetsFile
-	if err := json.Unmarshal(assetsData, &assets); err != nil {
-		return fmt.Errorf("could not parse assets.json: %w", err)
+	entries := []PreinstallEntry{}
+	for _, item := range items {
+		record, installed := state.Entries[item.key]
+		entries = append(entries, PreinstallEntry{
+			Key:         item.key,
+			Kind:        item.kind,
+			Name:        item.name,
+			Pack:        item.pack,
+			PackVersion: item.packVersion,
+			Installed:   installed,
+			UpToDate:    installed && preinstallUpToDate(item, record),
+		})
 	}
+	return entries, nil
+}
 
-	spicetifyPath := helpers.GetSpicetifyConfigDir()
+func (a *App) InstallPreinstallPack(opts PreinstallOptions) (PreinstallReport, error) {
+	report := PreinstallReport{Installed: []string{}, Skipped: []string{}, Failed: map[string]string{}}
 
-	for _, theme := range assets.Themes {
-		themeDir := filepath.Join(spicetifyPath, "Themes", theme.Name)
-		if err := os.MkdirAll(themeDir, 0755); err != nil {
-			return err
+	items, err := loadPreinstallItems(opts.PackPath)
+	if err != nil {
+		return report, err
+	}
+
+	for _, key := range opts.Selection {
+		if !slices.ContainsFunc(items, func(item preinstallItem) bool { return item.key == key }) {
+			report.Failed[key] = "unknown preinstall entry"
 		}
+	}
 
-		for _, fileURL := range theme.RawFiles {
-			parts := strings.Split(fileURL, "/")
-			fileName := strings.Split(parts[len(parts)-1], "?")[0]
-			fmt.Printf("[setup-assets] Downloading theme asset: %s\n", fileName)
-
-			content, err := downloadText(fileURL)
-			if err != nil {
-				fmt.Printf("[setup-assets] Warning: failed to download %s: %v\n", fileName, err)
-				continue
-			}
-			if err := os.WriteFile(filepath.Join(themeDir, fileName), []byte(content), 0644); err != nil {
-				return err
-			}
+	state := readPreinstallState()
+
+	for _, item := range items {
+		if len(opts.Selection) > 0 && !slices.Contains(opts.Selection, item.key) {
+			continue
+		}
+		if record, ok := state.Entries[item.key]; ok && !opts.Force && preinstallUpToDate(item, record) {
+			fmt.Printf("[setup-assets] %s is up to date, skipping\n", item.key)
+			report.Skipped = append(report.Skipped, item.key)
+			continue
+		}
+
+		files, err := item.install()
+		if err != nil {
+			report.Failed[item.key] = err.Error()
+			continue
 		}
 
-		metaPath := filepath.Join(themeDir, "theme.meta.json")
-		if theme.RawMetaContent != nil {
-			metaData, _ := json.MarshalIndent(theme.RawMetaContent, "", "  ")
-			_ = os.WriteFile(metaPath, metaData, 0644)
-		} else if theme.RawMetaURL != nil && *theme.RawMetaURL != "" {
-			content, err := downloadText(*theme.RawMetaURL)
-			if err == nil {
-				_ = os.WriteFile(metaPath, []byte(content), 0644)
-			}
+		state.Entries[item.key] = preinstallRecord{
+			Pack:        item.pack,
+			PackVersion: item.packVersion,
+			Fingerprint: item.fingerprint,
+			Files:       files,
+			InstalledAt: time.Now().UTC().Format(time.RFC3339),
 		}
+		report.Installed = append(report.Installed, item.key)
 	}
 
-	extDir := filepath.Join(spicetifyPath, "Extensions")
-	if err := os.MkdirAll(extDir, 0755); err != nil {
-		return err
+	if err := writePreinstallState(state); err != nil {
+		return report, fmt.Errorf("could not record installed assets: %w", err)
 	}
+	return report, nil
+}
 
-	for _, ext := range assets.Extensions {
-		for _, fileURL := range ext.RawFiles {
-			parts := strings.Split(fileURL, "/")
-			fileName := strings.Split(parts[len(parts)-1], "?")[0]
-			fmt.Printf("[setup-assets] Downloading extension: %s\n", fileName)
-
-			content, err := downloadText(fileURL)
-			if err != nil {
-				fmt.Printf("[setup-assets] Warning: failed to download %s: %v\n", fileName, err)
-				continue
-			}
-			if err := os.WriteFile(filepath.Join(extDir, fileName), []byte(content), 0644); err != nil {
-				return err
-			}
+// preinstallUpToDate reports whether an entry was installed from the same pack
+// version and definition and all of its files are still on disk.
+func preinstallUpToDate(item preinstallItem, record preinstallRecord) bool {
+	if record.PackVersion != item.packVersion || record.Fingerprint != item.fingerprint {
+		return false
+	}
+	spicetifyPath := helpers.GetSpicetifyConfigDir()
+	for _, f := range record.Files {
+		if !fileExists(filepath.Join(spicetifyPath, f)) {
+			return false
 		}
+	}
+	return true
+}
+
+func loadPreinstallItems(packPath string) ([]preinstallItem, error) {
+	assetsData, err := assets.PreinstallAssetsJSON.ReadFile("preinstall.json")
+	if err != nil {
+		return nil, fmt.Errorf("could not read preinstall.json: %w", err)
+	}
+	items, err := parsePreinstallPack("bundled", assetsData)
+	if err != nil {
+		return nil, err
+	}
+
+	if packPath == "" {
+		return items, nil
+	}
+
+	userData, err := os.ReadFile(packPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read preinstall pack %s: %w", packPath, err)
+	}
+	userItems, err := parsePreinstallPack(filepath.Base(packPath), userData)
+	if err != nil {
+		return nil, err
+	}
 
-		metaPath := filepath.Join(extDir, ext.FileName+".meta.json")
-		if ext.RawMetaContent != nil {
-			metaData, _ := json.MarshalIndent(ext.RawMetaContent, "", "  ")
-			_ = os.WriteFile(metaPath, metaData, 0644)
-		} else if ext.RawMetaURL != nil && *ext.RawMetaURL != "" {
-			content, err := downloadText(*ext.RawMetaURL)
-			if err == nil {
-				_ = os.WriteFile(metaPath, []byte(content), 0644)
-			}
+	for _, userItem := range userItems {
+		idx := slices.IndexFunc(items, func(item preinstallItem) bool { return item.key == userItem.key })
+		if idx >= 0 {
+			items[idx] = userItem
+		} else {
+			items = append(items, userItem)
 		}
 	}
+	return items, nil
+}
 
-	for _, app := range assets.Apps {
+func parsePreinstallPack(pack string, data []byte) ([]preinstallItem, error) {
+	var file assetsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("could not parse preinstall pack %s: %w", pack, err)
+	}
+
+	var items []preinstallItem
+
+	for _, theme := range file.Themes {
+		items = append(items, preinstallItem{
+			key:         "theme:" + theme.Name,
+			kind:        "theme",
+			name:        theme.Name,
+			pack:        pack,
+			packVersion: file.Version,
+			fingerprint: entryFingerprint(theme),
+			install:     func() ([]string, error) { return installAssetTheme(theme) },
+		})
+	}
+	for _, ext := range file.Extensions {
+		items = append(items, preinstallItem{
+			key:         "extension:" + ext.Name,
+			kind:        "extension",
+			name:        ext.Name,
+			pack:        pack,
+			packVersion: file.Version,
+			fingerprint: entryFingerprint(ext),
+			install:     func() ([]string, error) { return installAssetExtension(ext) },
+		})
+	}
+	for _, app := range file.Apps {
 		if app.RawArchiveURL == "" {
 			continue
 		}
-		fmt.Printf("[setup-assets] Downloading app: %s from %s\n", app.Name, app.RawArchiveURL)
+		items = append(items, preinstallItem{
+			key:         "app:" + app.Name,
+			kind:        "app",
+			name:        app.Name,
+			pack:        pack,
+			packVersion: file.Version,
+			fingerprint: entryFingerprint(app),
+			install:     func() ([]string, error) { return installAssetApp(app) },
+		})
+	}
 
-		resp, err := helpers.HttpGet(app.RawArchiveURL)
-		if err != nil {
-			fmt.Printf("[setup-assets] Warning: failed to download app %s: %v\n", app.Name, err)
-			continue
-		}
-		data, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			continue
+	return items, nil
+}
+
+func installAssetTheme(theme assetTheme) ([]string, error) {
+	spicetifyPath := helpers.GetSpicetifyConfigDir()
+	themeDir := filepath.Join(spicetifyPath, "Themes", theme.Name)
+
+	files, err := downloadRawFiles(theme.RawFiles, theme.Checksum)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(themeDir, 0755); err != nil {
+		return nil, err
+	}
+
+	var written []string
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(themeDir, f.name), f.content, 0644); err != nil {
+			return nil, err
 		}
+		written = append(written, filepath.Join("Themes", theme.Name, f.name))
+	}
+
+	writeAssetMeta(filepath.Join(themeDir, "theme.meta.json"), theme.RawMetaContent, theme.RawMetaURL)
+	return written, nil
+}
+
+func installAssetExtension(ext assetExtension) ([]string, error) {
+	spicetifyPath := helpers.GetSpicetifyConfigDir()
+	extDir := filepath.Join(spicetifyPath, "Extensions")
+
+	files, err := downloadRawFiles(ext.RawFiles, ext.Checksum)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(extDir, 0755); err != nil {
+		return nil, err
+	}
 
-		destDir := filepath.Join(spicetifyPath, "CustomApps", app.Name)
-		if err := os.MkdirAll(destDir, 0755); err != nil {
-			return err
+	var written []string
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(extDir, f.name), f.content, 0644); err != nil {
+			return nil, err
 		}
+		written = append(written, filepath.Join("Extensions", f.name))
+	}
 
-		if err := helpers.ExtractZipToDir(data, destDir, true); err != nil {
-			fmt.Printf("[setup-assets] Warning: failed to extract app %s: %v\n", app.Name, err)
-			continue
+	writeAssetMeta(filepath.Join(extDir, ext.FileName+".meta.json"), ext.RawMetaContent, ext.RawMetaURL)
+	return written, nil
+}
+
+func installAssetApp(app assetApp) ([]string, error) {
+	spicetifyPath := helpers.GetSpicetifyConfigDir()
+	if err := checkPinnedAsset([]string{app.RawArchiveURL}, app.Checksum); err != nil {
+		return nil, fmt.Errorf("app %s: %w", app.Name, err)
+	}
+	fmt.Printf("[setup-assets] Downloading app: %s from %s\n", app.Name, app.RawArchiveURL)
+
+	resp, err := helpers.HttpGet(app.RawArchiveURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download app %s: %w", app.Name, err)
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyChecksum(app.Checksum, data); err != nil {
+		return nil, fmt.Errorf("app %s: %w", app.Name, err)
+	}
+
+	destDir := filepath.Join(spicetifyPath, "CustomApps", app.Name)
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return nil, err
+	}
+	if err := helpers.ExtractZipToDir(data, destDir, true); err != nil {
+		return nil, fmt.Errorf("failed to extract app %s: %w", app.Name, err)
+	}
+
+	writeAssetMeta(filepath.Join(destDir, "app.meta.json"), app.RawMetaContent, app.RawMetaURL)
+	return []string{filepath.Join("CustomApps", app.Name)}, nil
+}
+
+type downloadedFile struct {
+	name    string
+	content []byte
+}
+
+// downloadRawFiles fetches every URL before anything is written, so a failed
+// download or checksum mismatch never leaves a half-installed entry behind.
+func downloadRawFiles(urls []string, checksum string) ([]downloadedFile, error) {
+	if err := checkPinnedAsset(urls, checksum); err != nil {
+		return nil, err
+	}
+	var files []downloadedFile
+	var payload []byte
+
+	for _, fileURL := range urls {
+		parts := strings.Split(fileURL, "/")
+		fileName := strings.Split(parts[len(parts)-1], "?")[0]
+		fmt.Printf("[setup-assets] Downloading asset: %s\n", fileName)
+
+		content, err := downloadText(fileURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download %s: %w", fileName, err)
 		}
+		files = append(files, downloadedFile{name: fileName, content: []byte(content)})
+		payload = append(payload, content...)
+	}
 
-		metaPath := filepath.Join(destDir, "app.meta.json")
-		if app.RawMetaContent != nil {
-			metaData, _ := json.MarshalIndent(app.RawMetaContent, "", "  ")
-			_ = os.WriteFile(metaPath, metaData, 0644)
-		} else if app.RawMetaURL != nil && *app.RawMetaURL != "" {
-			content, err := downloadText(*app.RawMetaURL)
-			if err == nil {
-				_ = os.WriteFile(metaPath, []byte(content), 0644)
-			}
+	if err := verifyChecksum(checksum, payload); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// checkPinnedAsset refuses an entry that could change under us: one with no
+// checksum or one downloading from a branch.
+func checkPinnedAsset(urls []string, checksum string) error {
+	if checksum == "" {
+		return fmt.Errorf("no checksum is pinned for this asset")
+	}
+	for _, url := range urls {
+		if strings.Contains(url, "/refs/heads/") {
+			return fmt.Errorf("%s follows a branch instead of a commit", url)
 		}
 	}
+	return nil
+}
 
+func verifyChecksum(expected string, payload []byte) error {
+	sum := sha256.Sum256(payload)
+	if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", expected, actual)
+	}
 	return nil
 }
+
+func writeAssetMeta(metaPath string, content map[string]any, metaURL *string) {
+	if content != nil {
+		metaData, _ := json.MarshalIndent(content, "", "  ")
+		_ = os.WriteFile(metaPath, metaData, 0644)
+	} else if metaURL != nil && *metaURL != "" {
+		if text, err := downloadText(*metaURL); err == nil {
+			_ = os.WriteFile(metaPath, []byte(text), 0644)
+		}
+	}
+}
+
+// entryFingerprint hashes an entry's definition so edits to a pack (new URLs,
+// metadata or checksum) are detected even when the pack version is unchanged.
+func entryFingerprint(entry any) string {
+	data, _ := json.Marshal(entry)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func readPreinstallState() preinstallState {
+	state := preinstallState{Entries: map[string]preinstallRecord{}}
+	data, err := os.ReadFile(helpers.GetPreinstallStatePath())
+	if err != nil {
+		return state
+	}
+	_ = json.Unmarshal(data, &state)
+	if state.Entries == nil {
+		state.Entries = map[string]preinstallRecord{}
+	}
+	return state
+}
+
+func writePreinstallState(state preinstallState) error {
+	if err := os.MkdirAll(helpers.GetSpicetifyxDir(), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(helpers.GetPreinstallStatePath(), data, 0644)
+}
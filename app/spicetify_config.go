@@ -0,0 +1,149 @@
+package app
+
+import (
+	"fmt"
+	"manager/internal/helpers"
+	"manager/internal/spiceconfig"
+	"maps"
+	"slices"
+	"strings"
+)
+
+type SpicetifyPreprocesses struct {
+	DisableSentry    bool `json:"disableSentry"`
+	DisableUILogging bool `json:"disableUiLogging"`
+	RemoveRTLRule    bool `json:"removeRtlRule"`
+	ExposeAPIs       bool `json:"exposeApis"`
+}
+
+// SpicetifyConfig mirrors the user-tunable options of config-xpui.ini.
+// Theme, color scheme, extensions and custom apps have their own APIs.
+type SpicetifyConfig struct {
+	InjectCSS            bool                  `json:"injectCss"`
+	InjectThemeJS        bool                  `json:"injectThemeJs"`
+	ReplaceColors        bool                  `json:"replaceColors"`
+	OverwriteAssets      bool                  `json:"overwriteAssets"`
+	SidebarConfig        bool                  `json:"sidebarConfig"`
+	HomeConfig           bool                  `json:"homeConfig"`
+	ExperimentalFeatures bool                  `json:"experimentalFeatures"`
+	SpotifyLaunchFlags   []string              `json:"spotifyLaunchFlags"`
+	CheckSpicetifyUpdate bool                  `json:"checkSpicetifyUpdate"`
+	Preprocesses         SpicetifyPreprocesses `json:"preprocesses"`
+}
+
+func (a *App) GetSpicetifyConfig() SpicetifyConfig {
+	return spicetifyConfigFromFile(helpers.ReadSpicetifyConfig())
+}
+
+// UpdateSpicetifyConfig validates cfg and writes every option that differs
+// from the current config in a single config transaction.
+func (a *App) UpdateSpicetifyConfig(cfg SpicetifyConfig) (SpicetifyConfig, error) {
+	if err := validateSpicetifyConfig(cfg, a.GetSpicetifyConfig()); err != nil {
+		return a.GetSpicetifyConfig(), err
+	}
+
+	values := spicetifyConfigValues(cfg)
+	tx := newConfigTransaction("Update Spicetify settings")
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		tx.Set(key, values[key])
+	}
+
+	if _, err := tx.Commit(); err != nil {
+		return a.GetSpicetifyConfig(), err
+	}
+	return a.GetSpicetifyConfig(), nil
+}
+
+func spicetifyConfigFromFile(f *spiceconfig.File) SpicetifyConfig {
+	return SpicetifyConfig{
+		InjectCSS:            f.Bool("inject_css"),
+		InjectThemeJS:        f.Bool("inject_theme_js"),
+		ReplaceColors:        f.Bool("replace_colors"),
+		OverwriteAssets:      f.Bool("overwrite_assets"),
+		SidebarConfig:        f.Bool("sidebar_config"),
+		HomeConfig:           f.Bool("home_config"),
+		ExperimentalFeatures: f.Bool("experimental_features"),
+		SpotifyLaunchFlags:   f.List("spotify_launch_flags"),
+		CheckSpicetifyUpdate: f.Bool("check_spicetify_update"),
+		Preprocesses: SpicetifyPreprocesses{
+			DisableSentry:    f.Bool("disable_sentry"),
+			DisableUILogging: f.Bool("disable_ui_logging"),
+			RemoveRTLRule:    f.Bool("remove_rtl_rule"),
+			ExposeAPIs:       f.Bool("expose_apis"),
+		},
+	}
+}
+
+// spicetifyConfigValues renders cfg as config-xpui.ini values keyed by the
+// names the spicetify CLI expects.
+func spicetifyConfigValues(cfg SpicetifyConfig) map[string]string {
+	return map[string]string{
+		"inject_css":             iniBool(cfg.InjectCSS),
+		"inject_theme_js":        iniBool(cfg.InjectThemeJS),
+		"replace_colors":         iniBool(cfg.ReplaceColors),
+		"overwrite_assets":       iniBool(cfg.OverwriteAssets),
+		"sidebar_config":         iniBool(cfg.SidebarConfig),
+		"home_config":            iniBool(cfg.HomeConfig),
+		"experimental_features":  iniBool(cfg.ExperimentalFeatures),
+		"spotify_launch_flags":   spiceconfig.JoinList(cfg.SpotifyLaunchFlags),
+		"check_spicetify_update": iniBool(cfg.CheckSpicetifyUpdate),
+		"disable_sentry":         iniBool(cfg.Preprocesses.DisableSentry),
+		"disable_ui_logging":     iniBool(cfg.Preprocesses.DisableUILogging),
+		"remove_rtl_rule":        iniBool(cfg.Preprocesses.RemoveRTLRule),
+		"expose_apis":            iniBool(cfg.Preprocesses.ExposeAPIs),
+	}
+}
+
+// validateSpicetifyConfig checks cfg before it replaces current.
+func validateSpicetifyConfig(cfg, current SpicetifyConfig) error {
+	for _, flag := range cfg.SpotifyLaunchFlags {
+		switch {
+		case !strings.HasPrefix(flag, "--") || len(flag) < 3:
+			return fmt.Errorf("launch flag %q must start with --", flag)
+		case strings.Contains(flag, spiceconfig.ListSeparator):
+			return fmt.Errorf("launch flag %q must not contain %q", flag, spiceconfig.ListSeparator)
+		case strings.ContainsAny(flag, " \t\r\n"):
+			return fmt.Errorf("launch flag %q must not contain whitespace", flag)
+		case strings.HasSuffix(flag, "-"):
+			// The CLI reads a trailing "-" as "remove this entry".
+			return fmt.Errorf("launch flag %q must not end with -", flag)
+		}
+	}
+	if dup := duplicateLaunchFlag(cfg.SpotifyLaunchFlags); dup != "" {
+		return fmt.Errorf("launch flag %q is listed more than once", dup)
+	}
+
+	// These features are implemented on top of the APIs spicetify exposes
+	// to xpui and silently do nothing without them. Only turning one on is
+	// refused, so a config that already has them can still be saved.
+	if !cfg.Preprocesses.ExposeAPIs {
+		switch {
+		case cfg.SidebarConfig && !current.SidebarConfig:
+			return fmt.Errorf("sidebar_config requires expose_apis to be enabled")
+		case cfg.HomeConfig && !current.HomeConfig:
+			return fmt.Errorf("home_config requires expose_apis to be enabled")
+		case cfg.ExperimentalFeatures && !current.ExperimentalFeatures:
+			return fmt.Errorf("experimental_features requires expose_apis to be enabled")
+		}
+	}
+	return nil
+}
+
+func duplicateLaunchFlag(flags []string) string {
+	seen := []string{}
+	for _, flag := range flags {
+		name, _, _ := strings.Cut(flag, "=")
+		if slices.Contains(seen, name) {
+			return flag
+		}
+		seen = append(seen, name)
+	}
+	return ""
+}
+
+func iniBool(v bool) string {
+	if v {
+		return "1"
+	}
+	return "0"
+}
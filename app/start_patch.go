@@ -17,24 +17,18 @@ func (a *App) StartInstall() {
 
 		exec := helpers.GetSpicetifyExec()
 
-		if err := helpers.SpicetifyCommand(exec, []string{"config", "always_enable_devtools", "1"}, nil); err != nil {
+		tx := newConfigTransaction("Install SpicetifyX").
+			Set("always_enable_devtools", "1").
+			Set("current_theme", "SpicetifyX").
+			Set("color_scheme", "main").
+			Append("extensions", "adblock.js").
+			Append("extensions", "spotifyGenres.js").
+			Append("extensions", "spicetifyx.js")
+		if _, err := tx.Commit(); err != nil {
 			wailsRuntime.EventsEmit(a.ctx, "install-complete", map[string]any{"success": false, "error": err.Error()})
 			return
 		}
 
-		if err := helpers.SpicetifyCommand(exec, []string{"config", "current_theme", "SpicetifyX"}, nil); err != nil {
-			wailsRuntime.EventsEmit(a.ctx, "install-complete", map[string]any{"success": false, "error": err.Error()})
-			return
-		}
-		if err := helpers.SpicetifyCommand(exec, []string{"config", "color_scheme", "main"}, nil); err != nil {
-			wailsRuntime.EventsEmit(a.ctx, "install-complete", map[string]any{"success": false, "error": err.Error()})
-			return
-		}
-
-		_ = helpers.SpicetifyCommand(exec, []string{"config", "extensions", "adblock.js"}, nil)
-		_ = helpers.SpicetifyCommand(exec, []string{"config", "extensions", "spotifyGenres.js"}, nil)
-		_ = helpers.SpicetifyCommand(exec, []string{"config", "extensions", "spicetifyx.js"}, nil)
-
 		if err := helpers.SpicetifyCommand(exec, []string{"backup", "apply"}, sendOutput); err != nil {
 			wailsRuntime.EventsEmit(a.ctx, "install-complete", map[string]any{"success": false, "error": err.Error()})
 			return
@@ -0,0 +1,232 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"manager/internal/helpers"
+	"manager/internal/spiceconfig"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// themePreview is a marketplace theme shown in Spotify before it is
+// installed. Its files live in a temp dir until the preview is confirmed or
+// cancelled.
+type themePreview struct {
+	mu sync.Mutex
+	themePreviewSession
+}
+
+type themePreviewSession struct {
+	active  bool
+	dir     string
+	themeID string
+	meta    *MarketplaceMeta
+	schemes map[string]map[string]string
+	order   []string
+	scheme  string
+}
+
+type ThemePreviewStatus struct {
+	Active  bool     `json:"active"`
+	Theme   string   `json:"theme,omitempty"`
+	Schemes []string `json:"schemes"`
+	Scheme  string   `json:"scheme,omitempty"`
+}
+
+func (p *themePreviewSession) status() ThemePreviewStatus {
+	status := ThemePreviewStatus{Schemes: []string{}}
+	if p.active {
+		status.Active = true
+		status.Theme = p.themeID
+		status.Schemes = append(status.Schemes, p.order...)
+		status.Scheme = p.scheme
+	}
+	return status
+}
+
+// cleanup removes the downloaded files and clears the session.
+func (p *themePreview) cleanup() {
+	if p.dir != "" {
+		os.RemoveAll(p.dir)
+	}
+	p.themePreviewSession = themePreviewSession{}
+}
+
+func (a *App) themePreviewActive() bool {
+	a.themePreview.mu.Lock()
+	defer a.themePreview.mu.Unlock()
+	return a.themePreview.active
+}
+
+// StartThemePreview downloads a marketplace theme to a temp dir and pushes
+// its CSS and a scheme to Spotify. An empty scheme picks the first one. The
+// arguments match InstallMarketplaceTheme; the temp dir holds the same files
+// an install would write, so confirming only has to copy them.
+func (a *App) StartThemePreview(themeID, cssURL string, schemesURL *string, include []string, meta *MarketplaceMeta, scheme string) (ThemePreviewStatus, error) {
+	if themeID == "" || filepath.Base(themeID) != themeID {
+		return ThemePreviewStatus{}, fmt.Errorf("invalid theme %q", themeID)
+	}
+
+	css, err := downloadText(cssURL)
+	if err != nil {
+		return ThemePreviewStatus{}, fmt.Errorf("could not download CSS: %w", err)
+	}
+	schemes := spiceconfig.Parse(nil)
+	if schemesURL != nil && *schemesURL != "" {
+		content, err := downloadText(*schemesURL)
+		if err != nil {
+			return ThemePreviewStatus{}, fmt.Errorf("could not download color schemes: %w", err)
+		}
+		schemes = spiceconfig.Parse([]byte(content))
+	}
+
+	dir, err := os.MkdirTemp("", "spicetifyx-theme-preview-*")
+	if err != nil {
+		return ThemePreviewStatus{}, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "user.css"), []byte(css), 0644); err != nil {
+		os.RemoveAll(dir)
+		return ThemePreviewStatus{}, err
+	}
+	if len(schemes.Sections()) > 0 {
+		if err := schemes.Save(filepath.Join(dir, "color.ini")); err != nil {
+			os.RemoveAll(dir)
+			return ThemePreviewStatus{}, err
+		}
+	}
+	// Included files (theme.js and the like) are not previewed, but they
+	// are part of the install.
+	for _, incURL := range include {
+		if !strings.HasPrefix(incURL, "http") {
+			continue
+		}
+		parts := strings.Split(incURL, "/")
+		filename := parts[len(parts)-1]
+		if content, err := downloadText(incURL); err == nil {
+			_ = os.WriteFile(filepath.Join(dir, filename), []byte(content), 0644)
+		}
+	}
+
+	presets := make(map[string]map[string]string)
+	for _, section := range schemes.Sections() {
+		presets[section.Name()] = make(map[string]string)
+		for _, key := range section.Keys() {
+			presets[section.Name()][key], _ = section.Get(key)
+		}
+	}
+	order := schemes.SectionNames()
+	if scheme == "" && len(order) > 0 {
+		scheme = order[0]
+	}
+	if _, ok := presets[scheme]; scheme != "" && !ok {
+		os.RemoveAll(dir)
+		return ThemePreviewStatus{}, fmt.Errorf("scheme %s does not exist in %s", scheme, themeID)
+	}
+
+	a.themePreview.mu.Lock()
+	defer a.themePreview.mu.Unlock()
+	a.themePreview.cleanup()
+	a.themePreview.themePreviewSession = themePreviewSession{
+		active:  true,
+		dir:     dir,
+		themeID: themeID,
+		meta:    meta,
+		schemes: presets,
+		order:   order,
+		scheme:  scheme,
+	}
+
+	BroadcastLiveCSS(themeID, css, nil)
+	if scheme != "" {
+		BroadcastLiveColors(themeID, scheme, presets[scheme])
+	}
+	log.Printf("[ThemePreview] Previewing %s (%s)\n", themeID, scheme)
+	return a.themePreview.status(), nil
+}
+
+// SetThemePreviewScheme switches the previewed theme to another of its
+// schemes.
+func (a *App) SetThemePreviewScheme(scheme string) error {
+	a.themePreview.mu.Lock()
+	defer a.themePreview.mu.Unlock()
+	if !a.themePreview.active {
+		return fmt.Errorf("no theme preview is active")
+	}
+	colors, ok := a.themePreview.schemes[scheme]
+	if !ok {
+		return fmt.Errorf("scheme %s does not exist in %s", scheme, a.themePreview.themeID)
+	}
+	a.themePreview.scheme = scheme
+	BroadcastLiveColors(a.themePreview.themeID, scheme, colors)
+	return nil
+}
+
+func (a *App) GetThemePreviewStatus() ThemePreviewStatus {
+	a.themePreview.mu.Lock()
+	defer a.themePreview.mu.Unlock()
+	return a.themePreview.status()
+}
+
+// CancelThemePreview puts the applied theme and saved scheme back.
+func (a *App) CancelThemePreview() error {
+	a.themePreview.mu.Lock()
+	defer a.themePreview.mu.Unlock()
+	if !a.themePreview.active {
+		return nil
+	}
+	a.themePreview.cleanup()
+
+	restoreAppliedCSS()
+	return a.broadcastSavedColorScheme()
+}
+
+// ConfirmThemePreview installs the previewed theme from its temp files.
+// With apply it becomes current_theme with the previewed scheme and is
+// applied; without, Spotify goes back to the applied theme.
+func (a *App) ConfirmThemePreview(apply bool) error {
+	a.themePreview.mu.Lock()
+	if !a.themePreview.active {
+		a.themePreview.mu.Unlock()
+		return fmt.Errorf("no theme preview is active")
+	}
+	p := a.themePreview.themePreviewSession
+	a.themePreview.themePreviewSession = themePreviewSession{}
+	a.themePreview.mu.Unlock()
+	defer os.RemoveAll(p.dir)
+
+	destThemeDir := filepath.Join(helpers.GetThemesDir(), p.themeID)
+	if err := copyDirRecursive(p.dir, destThemeDir); err != nil {
+		restoreAppliedCSS()
+		_ = a.broadcastSavedColorScheme()
+		return fmt.Errorf("could not install %s: %w", p.themeID, err)
+	}
+	if p.meta != nil {
+		metaData, _ := json.MarshalIndent(p.meta, "", "  ")
+		_ = os.WriteFile(filepath.Join(destThemeDir, "theme.meta.json"), metaData, 0644)
+	}
+	log.Printf("[ThemePreview] Installed %s\n", p.themeID)
+
+	if !apply {
+		restoreAppliedCSS()
+		return a.broadcastSavedColorScheme()
+	}
+	tx := newConfigTransaction("Apply theme "+p.themeID).
+		Set("current_theme", p.themeID).
+		Set("color_scheme", p.scheme)
+	if _, err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not switch to %s: %w", p.themeID, err)
+	}
+	return helpers.SpicetifyCommand(helpers.GetSpicetifyExec(), []string{"apply"}, nil)
+}
+
+// cancelThemePreviewOnExit restores Spotify and removes the temp files when
+// the manager quits mid-preview.
+func (a *App) cancelThemePreviewOnExit() {
+	if err := a.CancelThemePreview(); err != nil {
+		log.Printf("[ThemePreview] Could not restore theme: %v\n", err)
+	}
+}
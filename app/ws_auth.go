@@ -0,0 +1,62 @@
+package app
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"manager/internal/helpers"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// spotifyOrigin is the origin the xpui bundle runs under in every desktop
+// build; nothing else has a reason to talk to the bridge.
+const spotifyOrigin = "https://xpui.app.spotify.com"
+
+var bridgeToken struct {
+	once  sync.Once
+	value string
+}
+
+// getBridgeToken returns the per-install secret the extension has to send
+// when it connects, creating it on first use.
+func getBridgeToken() string {
+	bridgeToken.once.Do(func() {
+		path := helpers.GetBridgeTokenPath()
+		if data, err := os.ReadFile(path); err == nil {
+			if token := strings.TrimSpace(string(data)); len(token) >= 32 {
+				bridgeToken.value = token
+				return
+			}
+		}
+
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			log.Printf("[LivePreview] Could not generate bridge token: %v\n", err)
+			return
+		}
+		token := hex.EncodeToString(buf)
+		_ = os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(token), 0600); err != nil {
+			log.Printf("[LivePreview] Could not save bridge token: %v\n", err)
+		}
+		bridgeToken.value = token
+	})
+	return bridgeToken.value
+}
+
+func checkBridgeOrigin(r *http.Request) bool {
+	return r.Header.Get("Origin") == spotifyOrigin
+}
+
+// checkBridgeToken compares the handshake's token query parameter in
+// constant time. Without a token nothing is accepted.
+func checkBridgeToken(r *http.Request) bool {
+	want := getBridgeToken()
+	got := r.URL.Query().Get("token")
+	return want != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
@@ -1,175 +1,211 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"manager/internal/helpers"
+	"manager/internal/spicecolor"
+	"manager/internal/wshub"
+	"net"
 	"net/http"
 	"os"
-	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins
-	},
+	CheckOrigin: checkBridgeOrigin,
 }
 
-type WSServer struct {
-	clients   map[*websocket.Conn]bool
-	broadcast chan interface{}
-	mu        sync.Mutex
+// wsServer is the live-preview bridge to the spicetifyx.js extension.
+var wsServer = struct {
+	mu      sync.Mutex
+	hub     *wshub.Hub
+	http    *http.Server
+	port    int
+	bindErr string
+}{hub: wshub.New()}
+
+// BridgeStatus reports whether the live-preview server is reachable.
+type BridgeStatus struct {
+	Listening bool   `json:"listening"`
+	Port      int    `json:"port,omitempty"`
+	Ports     []int  `json:"ports"`
+	Clients   int    `json:"clients"`
+	Error     string `json:"error,omitempty"`
 }
 
-var wsServer = &WSServer{
-	clients:   make(map[*websocket.Conn]bool),
-	broadcast: make(chan interface{}),
+// bridgePorts lists the ports the server may use, with the one it bound
+// last time first so the installed extension usually finds it right away.
+func bridgePorts() []int {
+	settings, _ := ReadSettings()
+	ports := []int{}
+	data, err := os.ReadFile(helpers.GetBridgePortPath())
+	if last, convErr := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && convErr == nil &&
+		last >= settings.BridgePortMin && last <= settings.BridgePortMax {
+		ports = append(ports, last)
+	}
+	for port := settings.BridgePortMin; port <= settings.BridgePortMax; port++ {
+		if !slices.Contains(ports, port) {
+			ports = append(ports, port)
+		}
+	}
+	return ports
 }
 
+// StartWSServer binds the first free port of the configured range. A bind
+// failure is recorded for GetBridgeStatus instead of stopping the app.
 func StartWSServer() {
-	http.HandleFunc("/ws", handleConnections)
-	go handleMessages()
+	wsServer.hub.OnMessage = handleBridgeMessage
+	wsServer.hub.OnDisconnect = forgetBridgeClient
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", handleConnections)
+
+	var ln net.Listener
+	var err error
+	for _, port := range bridgePorts() {
+		ln, err = net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+		if err == nil {
+			break
+		}
+	}
+
+	wsServer.mu.Lock()
+	defer wsServer.mu.Unlock()
+	if ln == nil {
+		wsServer.bindErr = fmt.Sprintf("no free port for the live-preview server: %v", err)
+		log.Printf("[LivePreview] %s\n", wsServer.bindErr)
+		return
+	}
 
-	log.Println("WebSocket server started on :3001")
+	port := ln.Addr().(*net.TCPAddr).Port
+	_ = os.MkdirAll(helpers.GetSpicetifyxDir(), 0755)
+	if err := os.WriteFile(helpers.GetBridgePortPath(), []byte(strconv.Itoa(port)), 0644); err != nil {
+		log.Printf("[LivePreview] Could not save bridge port: %v\n", err)
+	}
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	wsServer.http = srv
+	wsServer.port = port
+	wsServer.bindErr = ""
+
+	log.Printf("WebSocket server started on 127.0.0.1:%d\n", port)
 	go func() {
-		if err := http.ListenAndServe(":3001", nil); err != nil {
-			log.Fatal("ListenAndServe: ", err)
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("[LivePreview] WebSocket server stopped: %v\n", err)
+			wsServer.mu.Lock()
+			wsServer.bindErr = err.Error()
+			wsServer.mu.Unlock()
 		}
 	}()
 }
 
-func handleConnections(w http.ResponseWriter, r *http.Request) {
-	ws, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Fatal(err)
+func (a *App) GetBridgeStatus() BridgeStatus {
+	wsServer.mu.Lock()
+	defer wsServer.mu.Unlock()
+	return BridgeStatus{
+		Listening: wsServer.http != nil && wsServer.bindErr == "",
+		Port:      wsServer.port,
+		Ports:     bridgePorts(),
+		Clients:   wsServer.hub.Len(),
+		Error:     wsServer.bindErr,
 	}
-	defer ws.Close()
+}
 
+// StopWSServer stops accepting connections and closes the open ones.
+func StopWSServer(ctx context.Context) {
 	wsServer.mu.Lock()
-	wsServer.clients[ws] = true
+	srv := wsServer.http
+	wsServer.http = nil
+	wsServer.port = 0
 	wsServer.mu.Unlock()
 
-	for {
-		_, _, err := ws.ReadMessage()
-		if err != nil {
-			wsServer.mu.Lock()
-			delete(wsServer.clients, ws)
-			wsServer.mu.Unlock()
-			break
+	if srv != nil {
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("[LivePreview] WebSocket server shutdown: %v\n", err)
 		}
 	}
+	if err := wsServer.hub.Shutdown(ctx); err != nil {
+		log.Printf("[LivePreview] Clients did not disconnect cleanly: %v\n", err)
+	}
 }
 
-func handleMessages() {
-	for {
-		msg := <-wsServer.broadcast
-		wsServer.mu.Lock()
-		for client := range wsServer.clients {
-			err := client.WriteJSON(msg)
-			if err != nil {
-				log.Printf("error: %v", err)
-				client.Close()
-				delete(wsServer.clients, client)
-			}
-		}
-		wsServer.mu.Unlock()
+func handleConnections(w http.ResponseWriter, r *http.Request) {
+	if !checkBridgeToken(r) {
+		log.Printf("[LivePreview] Rejected connection from %s: bad token\n", r.RemoteAddr)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
 	}
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already answered with the error status.
+		log.Printf("[LivePreview] Rejected connection from %s: %v\n", r.RemoteAddr, err)
+		return
+	}
+	wsServer.hub.Serve(ws)
 }
 
-func BroadcastLiveColor(themeID, preset, key, value string) {
-	msg := map[string]string{
-		"type":    "color_update",
-		"themeID": themeID,
-		"preset":  preset,
-		"key":     key,
-		"value":   value,
+// broadcast queues msg for every connected Spotify client without blocking.
+func broadcast(msg bridgeMessage) {
+	if err := wsServer.hub.Broadcast(msg); err != nil {
+		log.Printf("[LivePreview] Could not encode message: %v\n", err)
 	}
-	wsServer.broadcast <- msg
 }
 
-func (a *App) BroadcastColorUpdate(themeID, preset, key, value string) {
-	BroadcastLiveColor(themeID, preset, key, value)
+// BroadcastLiveColor pushes a color to Spotify for live preview. References
+// without a fallback cannot be resolved here and are not sent.
+func BroadcastLiveColor(themeID, preset, key, value string) {
+	parsed, err := spicecolor.Parse(value)
+	if err != nil {
+		log.Printf("[LivePreview] Ignoring %s/%s %s: %v\n", preset, key, themeID, err)
+		return
+	}
+	color, ok := parsed.Resolved()
+	if !ok {
+		return
+	}
+	value = color.Hex()
+
+	broadcast(&colorUpdateMessage{
+		bridgeHeader: newBridgeHeader(msgColorUpdate),
+		ThemeID:      themeID,
+		Preset:       preset,
+		Key:          key,
+		Value:        value,
+	})
 }
 
-func (a *App) InstallSpicetifyXExtension() bool {
-	extDir := helpers.GetExtensionsDir()
-	_ = os.MkdirAll(extDir, 0755)
-
-	destPath := filepath.Join(extDir, "spicetifyx.js")
-	
-	// Extension code
-	content := `(function SpicetifyX() {
-    const WS_URL = "ws://localhost:3001/ws";
-    let socket;
-
-    function connect() {
-        socket = new WebSocket(WS_URL);
-
-        socket.onopen = () => {
-            console.log("[SpicetifyX] Connected to live preview server");
-        };
-
-        socket.onmessage = (event) => {
-            try {
-                const msg = JSON.parse(event.data);
-                if (msg.type === "color_update") {
-                    updateColor(msg.key, msg.value);
-                }
-            } catch (e) {
-                console.error("[SpicetifyX] Failed to parse message", e);
-            }
-        };
-
-        socket.onclose = () => {
-            console.log("[SpicetifyX] Disconnected from live preview server. Retrying in 5s...");
-            setTimeout(connect, 5000);
-        };
-
-        socket.onerror = (err) => {
-            console.error("[SpicetifyX] WebSocket error", err);
-            socket.close();
-        };
-    }
-
-    function updateColor(key, value) {
-        if (!key || !value) return;
-        
-        const hexValue = value.startsWith("#") ? value : "#" + value;
-        const rgbValue = hexToRGB(value);
-
-        document.documentElement.style.setProperty("--spice-" + key, hexValue);
-        document.documentElement.style.setProperty("--spice-rgb-" + key, rgbValue);
-    }
-
-    function hexToRGB(hex) {
-        hex = hex.replace("#", "");
-        if (hex.length === 3) {
-            hex = hex.split("").map(x => x + x).join("");
-        }
-        const r = parseInt(hex.slice(0, 2), 16);
-        const g = parseInt(hex.slice(2, 4), 16);
-        const b = parseInt(hex.slice(4, 6), 16);
-        return r + ", " + g + ", " + b;
-    }
-
-    if (!window.Spicetify || !Spicetify.Config) {
-        setTimeout(SpicetifyX, 1000);
-        return;
-    }
-
-    connect();
-})();`
-
-	err := os.WriteFile(destPath, []byte(content), 0644)
-	if err != nil {
-		return false
+// BroadcastLiveColors pushes a whole scheme in one message so Spotify
+// recolors in a single frame. Values that cannot be previewed are dropped.
+func BroadcastLiveColors(themeID, preset string, colors map[string]string) {
+	resolved := map[string]string{}
+	for key, value := range colors {
+		parsed, err := spicecolor.Parse(value)
+		if err != nil {
+			continue
+		}
+		if color, ok := parsed.Resolved(); ok {
+			resolved[key] = color.Hex()
+		}
 	}
+	broadcast(&colorsUpdateMessage{
+		bridgeHeader: newBridgeHeader(msgColorsUpdate),
+		ThemeID:      themeID,
+		Preset:       preset,
+		Colors:       resolved,
+	})
+}
 
-	// Enable extension
-	exec := helpers.GetSpicetifyExec()
-	_ = helpers.SpicetifyCommand(exec, []string{"config", "extensions", "spicetifyx.js"}, nil)
-	
-	return true
+func (a *App) BroadcastColorUpdate(themeID, preset, key, value string) {
+	BroadcastLiveColor(themeID, preset, key, value)
 }
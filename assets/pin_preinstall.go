@@ -0,0 +1,161 @@
+//go:build ignore
+
+// pin_preinstall rewrites preinstall.json so every download is reproducible:
+// raw.githubusercontent.com URLs that point at a branch are pinned to the
+// commit the branch is on now, and each entry gets the checksum the setup
+// step verifies (the hex SHA-256 of its raw files concatenated in order, or
+// of its archive). Run it from this directory after changing the manifest:
+//
+//	go run pin_preinstall.go
+//
+// Set GITHUB_TOKEN to avoid the API's anonymous rate limit.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const manifest = "preinstall.json"
+
+var branchURL = regexp.MustCompile(`https://raw\.githubusercontent\.com/([^/]+)/([^/]+)/refs/heads/([^/]+)/`)
+
+type entry struct {
+	Name          string   `json:"name"`
+	RawFiles      []string `json:"raw_files"`
+	RawArchiveURL string   `json:"raw_archive_url"`
+}
+
+type assetsFile struct {
+	Extensions []entry `json:"extensions"`
+	Themes     []entry `json:"themes"`
+	Apps       []entry `json:"apps"`
+}
+
+func main() {
+	data, err := os.ReadFile(manifest)
+	if err != nil {
+		log.Fatal(err)
+	}
+	text, err := pinBranches(string(data))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var assets assetsFile
+	if err := json.Unmarshal([]byte(text), &assets); err != nil {
+		log.Fatal(err)
+	}
+	for _, list := range [][]entry{assets.Extensions, assets.Themes, assets.Apps} {
+		for _, e := range list {
+			if text, err = setChecksum(text, e); err != nil {
+				log.Fatalf("%s: %v", e.Name, err)
+			}
+		}
+	}
+
+	if err := os.WriteFile(manifest, []byte(text), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// pinBranches replaces every refs/heads/<branch> in a raw URL with the
+// commit SHA the branch resolves to.
+func pinBranches(text string) (string, error) {
+	shas := map[string]string{}
+	for _, m := range branchURL.FindAllStringSubmatch(text, -1) {
+		ref := m[1] + "/" + m[2] + "/" + m[3]
+		if _, ok := shas[ref]; ok {
+			continue
+		}
+		sha, err := resolveBranch(m[1], m[2], m[3])
+		if err != nil {
+			return "", fmt.Errorf("resolving %s: %w", ref, err)
+		}
+		log.Printf("%s -> %s", ref, sha)
+		shas[ref] = sha
+	}
+	return branchURL.ReplaceAllStringFunc(text, func(url string) string {
+		m := branchURL.FindStringSubmatch(url)
+		return fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s/", m[1], m[2], shas[m[1]+"/"+m[2]+"/"+m[3]])
+	}), nil
+}
+
+func resolveBranch(owner, repo, branch string) (string, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("https://api.github.com/repos/%s/%s/commits/%s", owner, repo, branch), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github.sha")
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	body, err := fetch(req)
+	if err != nil {
+		return "", err
+	}
+	sha := strings.TrimSpace(string(body))
+	if len(sha) != 40 {
+		return "", fmt.Errorf("unexpected commit SHA %q", sha)
+	}
+	return sha, nil
+}
+
+// setChecksum writes e's checksum into the manifest text. The entry is found
+// by its first download URL, which is unique, and the checksum goes on the
+// line before the raw_files or raw_archive_url field, replacing an older
+// one.
+func setChecksum(text string, e entry) (string, error) {
+	urls := e.RawFiles
+	if e.RawArchiveURL != "" {
+		urls = []string{e.RawArchiveURL}
+	}
+	if len(urls) == 0 {
+		return "", fmt.Errorf("no download URLs")
+	}
+
+	var payload []byte
+	for _, url := range urls {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return "", err
+		}
+		body, err := fetch(req)
+		if err != nil {
+			return "", fmt.Errorf("downloading %s: %w", url, err)
+		}
+		payload = append(payload, body...)
+	}
+	sum := sha256.Sum256(payload)
+	checksum := hex.EncodeToString(sum[:])
+
+	field := regexp.MustCompile(`(?m)^([ \t]*)("checksum": "[0-9a-f]*",\n[ \t]*)?("raw_files": \[\s*|"raw_archive_url": )` + regexp.QuoteMeta(`"`+urls[0]+`"`))
+	loc := field.FindStringSubmatchIndex(text)
+	if loc == nil {
+		return "", fmt.Errorf("could not find %s in %s", urls[0], manifest)
+	}
+	indent := text[loc[2]:loc[3]]
+	line := indent + `"checksum": "` + checksum + `",` + "\n" + indent
+	log.Printf("%s: %s", e.Name, checksum)
+	return text[:loc[0]] + line + text[loc[6]:], nil
+}
+
+func fetch(req *http.Request) ([]byte, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status: %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,58 @@
+// Package extension embeds spicetifyx.js, the extension that connects
+// Spotify to the manager's live-preview bridge. The spicetifyx.js next to
+// this file is its only source; the manager fills in the per-install values
+// when it writes it.
+package extension
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// FileName is the name the extension is installed and registered under.
+const FileName = "spicetifyx.js"
+
+//go:embed spicetifyx.js
+var source string
+
+// Hash identifies the embedded source, independent of the per-install
+// values, so an installed copy can be traced back to a build.
+func Hash() string {
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:6])
+}
+
+// header starts every written copy. The manager compares whole files, so
+// the header is informational, but it tells a reader which build wrote it.
+func header() string {
+	return "// SpicetifyX bridge extension, source " + Hash() + ".\n" +
+		"// Written by SpicetifyX Manager; local changes are overwritten.\n"
+}
+
+// Render returns the extension with the bridge ports (the preferred one
+// first) and the connection token filled in.
+func Render(ports []int, token string) []byte {
+	portList := make([]string, len(ports))
+	for i, port := range ports {
+		portList[i] = strconv.Itoa(port)
+	}
+	body := strings.NewReplacer(
+		"__SPICETIFYX_PORTS__", strings.Join(portList, ", "),
+		"__SPICETIFYX_TOKEN__", token,
+	).Replace(source)
+	return []byte(header() + body)
+}
+
+// InstalledHash reads the source hash from the header of a written copy.
+// It is empty for copies written before the header existed.
+func InstalledHash(content []byte) string {
+	line, _, _ := strings.Cut(string(content), "\n")
+	_, rest, ok := strings.Cut(line, ", source ")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSuffix(rest, ".")
+}
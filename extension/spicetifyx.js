@@ -1,66 +1,378 @@
 (function SpicetifyX() {
-    const WS_URL = "ws://localhost:3001/ws";
+    // Must match bridgeProtocolVersion in app/bridge_protocol.go.
+    const PROTOCOL_VERSION = 1;
+
+    // The manager fills in its port range (the port it last bound first)
+    // and the per-install token when it writes this file. If the manager
+    // had to move to another port the extension walks the range to find it.
+    const PORTS = [__SPICETIFYX_PORTS__];
+    const TOKEN = "__SPICETIFYX_TOKEN__";
+    let portIndex = 0;
+    let nextID = 1;
     let socket;
 
+    // The extension's own errors go to the original console.error so they
+    // are not forwarded as problems; failed port probes would flood the log.
+    const logError = console.error.bind(console);
+
+    function send(type, fields) {
+        if (!socket || socket.readyState !== WebSocket.OPEN) return;
+        const msg = Object.assign({ v: PROTOCOL_VERSION, type: type, id: "ext-" + nextID++ }, fields);
+        socket.send(JSON.stringify(msg));
+    }
+
+    // notify sends a message the manager does not need to acknowledge.
+    function notify(type, fields) {
+        if (!socket || socket.readyState !== WebSocket.OPEN) return;
+        socket.send(JSON.stringify(Object.assign({ v: PROTOCOL_VERSION, type: type }, fields)));
+    }
+
+    function reply(request, type, fields) {
+        send(type, Object.assign({ replyTo: request.id }, fields));
+    }
+
     function connect() {
-        socket = new WebSocket(WS_URL);
+        const port = PORTS[portIndex % PORTS.length];
+        let opened = false;
+        socket = new WebSocket("ws://127.0.0.1:" + port + "/ws?token=" + TOKEN);
 
         socket.onopen = () => {
-            console.log("[SpicetifyX] Connected to live preview server");
+            opened = true;
+            console.log("[SpicetifyX] Connected to live preview server on port " + port);
+            send("hello", clientReport());
+            flushProblems();
+            reportPlayback();
         };
 
         socket.onmessage = (event) => {
+            let msg;
+            try {
+                msg = JSON.parse(event.data);
+            } catch (e) {
+                logError("[SpicetifyX] Failed to parse message", e);
+                return;
+            }
+            if (msg.v > PROTOCOL_VERSION) {
+                console.warn("[SpicetifyX] Manager speaks protocol v" + msg.v + ", this extension v" + PROTOCOL_VERSION);
+            }
             try {
-                const msg = JSON.parse(event.data);
-                if (msg.type === "color_update") {
-                    updateColor(msg.key, msg.value);
+                handle(msg);
+                if (msg.id && msg.type !== "status_request") {
+                    reply(msg, "ack", { ok: true });
                 }
             } catch (e) {
-                console.error("[SpicetifyX] Failed to parse message", e);
+                if (msg.id) {
+                    reply(msg, "ack", { ok: false, error: String(e) });
+                }
             }
         };
 
         socket.onclose = () => {
+            if (!opened) {
+                // Try the next port right away; pause after a full round.
+                portIndex = (portIndex + 1) % PORTS.length;
+                setTimeout(connect, portIndex === 0 ? 5000 : 200);
+                return;
+            }
             console.log("[SpicetifyX] Disconnected from live preview server. Retrying in 5s...");
             setTimeout(connect, 5000);
         };
 
         socket.onerror = (err) => {
-            console.error("[SpicetifyX] WebSocket error", err);
+            logError("[SpicetifyX] WebSocket error", err);
             socket.close();
         };
     }
 
+    function handle(msg) {
+        if (msg.type === "color_update") {
+            updateColor(msg.key, msg.value);
+        } else if (msg.type === "colors_update") {
+            replaceColors(msg.colors || {});
+        } else if (msg.type === "css_update") {
+            updateCSS(msg);
+        } else if (msg.type === "status_request") {
+            reply(msg, "status", clientReport());
+        } else if (msg.type === "playback_command") {
+            runPlaybackCommand(msg);
+        }
+    }
+
+    function playbackTrack() {
+        const data = Spicetify.Player.data || {};
+        const item = data.item || data.track;
+        if (!item) return null;
+        const meta = item.metadata || {};
+        const duration = (item.duration && item.duration.milliseconds) || Number(meta.duration) || 0;
+        return {
+            uri: item.uri || "",
+            title: item.name || meta.title || "",
+            artist: (item.artists || []).map(a => a.name).join(", ") || meta.artist_name || "",
+            album: (item.album && item.album.name) || meta.album_title || "",
+            imageURL: meta.image_xlarge_url || meta.image_url || "",
+            durationMs: duration,
+        };
+    }
+
+    function reportPlayback() {
+        const player = Spicetify.Player;
+        if (!player) return;
+        notify("playback", {
+            track: playbackTrack(),
+            isPlaying: !!player.isPlaying(),
+            progressMs: Math.round(player.getProgress() || 0),
+            volume: player.getVolume(),
+            liked: !!player.getHeart(),
+            shuffle: !!player.getShuffle(),
+            repeat: player.getRepeat() || 0,
+        });
+    }
+
+    // Progress (and with it volume) is reported every few seconds; the
+    // manager extrapolates in between. Track and play state changes go out
+    // right away.
+    const PROGRESS_INTERVAL = 5000;
+    let lastProgressReport = 0;
+
+    function watchPlayback() {
+        const player = Spicetify.Player;
+        if (!player || !player.addEventListener) return;
+        player.addEventListener("songchange", reportPlayback);
+        player.addEventListener("onplaypause", reportPlayback);
+        player.addEventListener("onprogress", () => {
+            const now = Date.now();
+            if (now - lastProgressReport < PROGRESS_INTERVAL) return;
+            lastProgressReport = now;
+            reportPlayback();
+        });
+    }
+
+    function runPlaybackCommand(msg) {
+        const player = Spicetify.Player;
+        switch (msg.command) {
+            case "play": player.play(); break;
+            case "pause": player.pause(); break;
+            case "toggle": player.togglePlay(); break;
+            case "next": player.next(); break;
+            case "previous": player.back(); break;
+            case "seek": player.seek(msg.positionMs); break;
+            case "volume": player.setVolume(msg.volume); break;
+            case "like":
+                if (!!player.getHeart() !== !!msg.liked) player.toggleHeart();
+                break;
+            default:
+                throw new Error("unknown playback command " + msg.command);
+        }
+        setTimeout(reportPlayback, 300);
+    }
+
+    // clientReport describes what is actually running inside Spotify, as
+    // opposed to what config-xpui.ini asks for.
+    function clientReport() {
+        const config = Spicetify.Config || {};
+        return {
+            spotifyVersion: (Spicetify.Platform && Spicetify.Platform.version) || "",
+            spicetifyVersion: config.version || "",
+            theme: config.current_theme || "",
+            colorScheme: config.color_scheme || "",
+            extensions: loadedExtensions(),
+            variables: themeVariables(),
+        };
+    }
+
+    function loadedExtensions() {
+        const names = new Set();
+        performance.getEntriesByType("resource").forEach(entry => {
+            const match = entry.name.match(/\/extensions\/([^?#]+\.m?js)/);
+            if (match) names.add(decodeURIComponent(match[1]));
+        });
+        return Array.from(names);
+    }
+
+    function themeVariables() {
+        const names = new Set();
+        const collect = (style) => {
+            for (let i = 0; i < style.length; i++) {
+                const name = style[i];
+                if (name.startsWith("--spice-") && !name.startsWith("--spice-rgb-")) names.add(name);
+            }
+        };
+        Array.from(document.styleSheets).forEach(sheet => {
+            let rules;
+            try {
+                rules = sheet.cssRules;
+            } catch (e) {
+                return;
+            }
+            Array.from(rules).forEach(rule => {
+                if (rule.selectorText === ":root" && rule.style) collect(rule.style);
+            });
+        });
+        collect(document.documentElement.style);
+
+        const computed = getComputedStyle(document.documentElement);
+        const variables = {};
+        names.forEach(name => {
+            variables[name.slice("--spice-".length)] = computed.getPropertyValue(name).trim();
+        });
+        return variables;
+    }
+
+    // Problems raised before the socket is open are kept until it is, so
+    // an extension that breaks Spotify at startup still shows up.
+    const MAX_QUEUED_PROBLEMS = 50;
+    let queuedProblems = [];
+    let reporting = false;
+
+    function reportProblem(fields) {
+        if (reporting) return;
+        reporting = true;
+        try {
+            if (socket && socket.readyState === WebSocket.OPEN) {
+                send("error", fields);
+            } else if (queuedProblems.length < MAX_QUEUED_PROBLEMS) {
+                queuedProblems.push(fields);
+            }
+        } finally {
+            reporting = false;
+        }
+    }
+
+    function flushProblems() {
+        const queued = queuedProblems;
+        queuedProblems = [];
+        queued.forEach(fields => send("error", fields));
+    }
+
+    function describe(value) {
+        if (value instanceof Error) return value.message;
+        if (typeof value === "string") return value;
+        try {
+            return JSON.stringify(value);
+        } catch (e) {
+            return String(value);
+        }
+    }
+
+    // Drops the "Error" line and the frames of callerStack and the
+    // console.error hook, leaving the caller on top.
+    function callerStack() {
+        return (new Error().stack || "").split("\n").slice(3).join("\n");
+    }
+
+    function watchErrors() {
+        window.addEventListener("error", (event) => {
+            reportProblem({
+                kind: "error",
+                message: event.message || describe(event.error),
+                source: event.filename || "",
+                line: event.lineno || 0,
+                column: event.colno || 0,
+                stack: (event.error && event.error.stack) || "",
+            });
+        });
+
+        window.addEventListener("unhandledrejection", (event) => {
+            const reason = event.reason;
+            reportProblem({
+                kind: "rejection",
+                message: describe(reason),
+                stack: (reason && reason.stack) || "",
+            });
+        });
+
+        // The stack of a fresh Error shows who called console.error, which
+        // is how the manager tells which extension logged it.
+        console.error = function (...args) {
+            logError(...args);
+            const thrown = args.find(arg => arg instanceof Error);
+            reportProblem({
+                kind: "console",
+                message: args.map(describe).join(" "),
+                stack: (thrown && thrown.stack) || callerStack(),
+            });
+        };
+    }
+
+    // Keys this extension has overridden inline, so a whole-scheme update
+    // can drop the ones the new scheme does not have and let the saved
+    // colors.css show through again.
+    const overridden = new Set();
+
     function updateColor(key, value) {
         if (!key || !value) return;
-        
-        // Prepend # if not present
-        const hexValue = value.startsWith('#') ? value : `#${value}`;
+
+        const hexValue = value.startsWith("#") ? value : "#" + value;
         const rgbValue = hexToRGB(value);
 
-        // Set CSS variables on :root
-        document.documentElement.style.setProperty(`--spice-${key}`, hexValue);
-        document.documentElement.style.setProperty(`--spice-rgb-${key}`, rgbValue);
-        
-        console.log(`[SpicetifyX] Updated ${key} to ${hexValue} (${rgbValue})`);
+        document.documentElement.style.setProperty("--spice-" + key, hexValue);
+        document.documentElement.style.setProperty("--spice-rgb-" + key, rgbValue);
+        overridden.add(key);
+    }
+
+    function replaceColors(colors) {
+        overridden.forEach(key => {
+            if (key in colors) return;
+            document.documentElement.style.removeProperty("--spice-" + key);
+            document.documentElement.style.removeProperty("--spice-rgb-" + key);
+            overridden.delete(key);
+        });
+        Object.entries(colors).forEach(([key, value]) => updateColor(key, value));
+    }
+
+    // Pushed CSS goes into <style> layers; the snippet layer always comes
+    // last so it wins over the theme. The user.css link spicetify injected
+    // is disabled rather than removed so a reset can bring it back.
+    function styleLayer(id) {
+        let el = document.getElementById(id);
+        if (!el) {
+            el = document.createElement("style");
+            el.id = id;
+        }
+        return el;
+    }
+
+    function updateCSS(msg) {
+        const links = document.querySelectorAll('link.userCSS[href$="user.css"]');
+        const themeLayer = styleLayer("spicetifyx-theme-css");
+        const snippetLayer = styleLayer("spicetifyx-snippet-css");
+
+        if (msg.reset) {
+            links.forEach(link => { link.disabled = false; });
+            themeLayer.remove();
+            snippetLayer.remove();
+            return;
+        }
+        if (typeof msg.css === "string") {
+            links.forEach(link => { link.disabled = true; });
+            themeLayer.textContent = msg.css;
+            document.head.appendChild(themeLayer);
+        }
+        if (typeof msg.snippet === "string") {
+            snippetLayer.textContent = msg.snippet;
+        }
+        if (snippetLayer.textContent) {
+            document.head.appendChild(snippetLayer);
+        }
     }
 
     function hexToRGB(hex) {
-        hex = hex.replace('#', '');
+        hex = hex.replace("#", "");
         if (hex.length === 3) {
-            hex = hex.split('').map(x => x + x).join('');
+            hex = hex.split("").map(x => x + x).join("");
         }
         const r = parseInt(hex.slice(0, 2), 16);
         const g = parseInt(hex.slice(2, 4), 16);
         const b = parseInt(hex.slice(4, 6), 16);
-        return `${r}, ${g}, ${b}`;
+        return r + ", " + g + ", " + b;
     }
 
-    // Initialize
-    if (!Spicetify.Config) {
+    if (!window.Spicetify || !Spicetify.Config) {
         setTimeout(SpicetifyX, 1000);
         return;
     }
 
+    watchErrors();
+    watchPlayback();
     connect();
 })();
@@ -50,3 +50,43 @@ func GetSettingsPath() string {
 func GetAppPath() string {
 	return GetSpicetifyConfigDir()
 }
+
+func GetPreinstallStatePath() string {
+	return filepath.Join(GetSpicetifyxDir(), "preinstall-state.json")
+}
+
+func GetHistoryDir() string {
+	return filepath.Join(GetSpicetifyxDir(), "history")
+}
+
+// GetDesktopPaletteFiles returns where pywal and wallust leave the current
+// desktop palette, both in pywal's colors.json format.
+func GetDesktopPaletteFiles() []string {
+	cacheDir := os.Getenv("XDG_CACHE_HOME")
+	if cacheDir == "" {
+		home, _ := os.UserHomeDir()
+		cacheDir = filepath.Join(home, ".cache")
+	}
+	return []string{
+		filepath.Join(cacheDir, "wal", "colors.json"),
+		filepath.Join(cacheDir, "wallust", "colors.json"),
+	}
+}
+
+func GetSchedulePath() string {
+	return filepath.Join(GetSpicetifyxDir(), "schedule.json")
+}
+
+// GetScheduleStatePath records the last switch the scheduler made, so a
+// restart does not redo it.
+func GetScheduleStatePath() string {
+	return filepath.Join(GetSpicetifyxDir(), "schedule-state.json")
+}
+
+func GetBridgeTokenPath() string {
+	return filepath.Join(GetSpicetifyxDir(), "bridge-token")
+}
+
+func GetBridgePortPath() string {
+	return filepath.Join(GetSpicetifyxDir(), "bridge-port")
+}
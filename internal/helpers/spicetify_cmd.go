@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"manager/internal/spiceconfig"
+	"os"
 	"os/exec"
 	"sync"
 )
@@ -17,7 +19,10 @@ var spicetifyMu sync.Mutex
 func SpicetifyCommand(execPath string, args []string, onData func(string)) error {
 	spicetifyMu.Lock()
 	defer spicetifyMu.Unlock()
+	return spicetifyCommand(execPath, args, onData)
+}
 
+func spicetifyCommand(execPath string, args []string, onData func(string)) error {
 	cmd := exec.Command(execPath, args...)
 	hideWindowIfNeeded(cmd)
 
@@ -69,3 +74,63 @@ func SpicetifyCommand(execPath string, args []string, onData func(string)) error
 
 	return runErr
 }
+
+// SpicetifyConfig runs `spicetify config` with the given key/value pairs. When
+// the CLI binary is missing the change is written straight to config-xpui.ini
+// with the same append/remove semantics for list keys.
+func SpicetifyConfig(args []string) error {
+	spicetifyMu.Lock()
+	defer spicetifyMu.Unlock()
+	return spicetifyConfig(args)
+}
+
+// UpdateSpicetifyConfig reads config-xpui.ini, lets update turn it into
+// `spicetify config` arguments and writes those, all under the lock every
+// spicetify invocation takes, so nothing can change the file in between.
+// Empty arguments write nothing.
+func UpdateSpicetifyConfig(update func(cfg *spiceconfig.File) ([]string, error)) error {
+	spicetifyMu.Lock()
+	defer spicetifyMu.Unlock()
+
+	args, err := update(ReadSpicetifyConfig())
+	if err != nil || len(args) == 0 {
+		return err
+	}
+	return spicetifyConfig(args)
+}
+
+// WithSpicetifyLock runs fn while holding the lock every spicetify
+// invocation takes, for changes that write config-xpui.ini directly.
+func WithSpicetifyLock(fn func() error) error {
+	spicetifyMu.Lock()
+	defer spicetifyMu.Unlock()
+	return fn()
+}
+
+func spicetifyConfig(args []string) error {
+	execPath := GetSpicetifyExec()
+	if _, err := os.Stat(execPath); err == nil {
+		return spicetifyCommand(execPath, append([]string{"config"}, args...), nil)
+	}
+
+	log.Printf("[SpicetifyConfig] CLI not found, writing config directly: %v\n", args)
+	configPath := GetConfigFilePath()
+	cfg, err := spiceconfig.Load(configPath)
+	if err != nil {
+		return err
+	}
+	if err := cfg.ApplyCLI(args); err != nil {
+		return err
+	}
+	return cfg.Save(configPath)
+}
+
+// ReadSpicetifyConfig parses config-xpui.ini. A missing or unreadable file
+// yields an empty config so callers can treat every key as unset.
+func ReadSpicetifyConfig() *spiceconfig.File {
+	cfg, err := spiceconfig.Load(GetConfigFilePath())
+	if err != nil {
+		return spiceconfig.Parse(nil)
+	}
+	return cfg
+}
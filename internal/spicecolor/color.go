@@ -0,0 +1,322 @@
+// Package spicecolor parses the color values found in theme color.ini files
+// and renders them in the canonical form the spicetify CLI reads back: lower
+// case hex without a leading "#".
+package spicecolor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type Color struct {
+	R, G, B, A uint8
+}
+
+// Hex renders c as rrggbb, or rrggbbaa when it is not fully opaque.
+func (c Color) Hex() string {
+	if c.A == 255 {
+		return fmt.Sprintf("%02x%02x%02x", c.R, c.G, c.B)
+	}
+	return fmt.Sprintf("%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+}
+
+// Value is a parsed color.ini value: either a concrete color or a ${...}
+// reference spicetify resolves at apply time (Xresources via ${xrdb:name} or
+// an environment variable), optionally with a fallback color.
+type Value struct {
+	Color    Color
+	Ref      string
+	Fallback *Color
+}
+
+func (v Value) IsRef() bool {
+	return v.Ref != ""
+}
+
+// String is the canonical color.ini form. References are kept verbatim.
+func (v Value) String() string {
+	if v.IsRef() {
+		return v.Ref
+	}
+	return v.Color.Hex()
+}
+
+// Resolved returns the color to preview a value with: the color itself, or
+// the fallback of a reference.
+func (v Value) Resolved() (Color, bool) {
+	if !v.IsRef() {
+		return v.Color, true
+	}
+	if v.Fallback != nil {
+		return *v.Fallback, true
+	}
+	return Color{}, false
+}
+
+// Parse accepts hex (3, 4, 6 or 8 digits, with or without "#"), rgb()/rgba(),
+// hsl()/hsla(), spicetify's bare "r,g,b" form and ${...} references.
+func Parse(raw string) (Value, error) {
+	s := strings.TrimSpace(raw)
+	if s == "" {
+		return Value{}, fmt.Errorf("empty color value")
+	}
+
+	if strings.HasPrefix(s, "${") {
+		return parseRef(s)
+	}
+
+	lower := strings.ToLower(s)
+	var (
+		c   Color
+		err error
+	)
+	switch {
+	case strings.HasPrefix(lower, "rgb"):
+		c, err = parseRGBFunc(lower)
+	case strings.HasPrefix(lower, "hsl"):
+		c, err = parseHSLFunc(lower)
+	case strings.Contains(lower, ","):
+		c, err = parseRGBArgs(strings.Split(lower, ","))
+	default:
+		c, err = parseHex(lower)
+	}
+	if err != nil {
+		return Value{}, fmt.Errorf("invalid color %q: %w", raw, err)
+	}
+	return Value{Color: c}, nil
+}
+
+// Normalize returns raw in canonical form.
+func Normalize(raw string) (string, error) {
+	v, err := Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	return v.String(), nil
+}
+
+// NormalizeOpaque is Normalize for values written to color.ini. The
+// spicetify CLI only reads 3 or 6 hex digits, so colors with alpha, or
+// references falling back to one, are rejected; the 8-digit form is for live
+// previews only.
+func NormalizeOpaque(raw string) (string, error) {
+	v, err := Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	if (!v.IsRef() && v.Color.A != 255) || (v.Fallback != nil && v.Fallback.A != 255) {
+		return "", fmt.Errorf("color %q is not opaque; color.ini colors cannot have alpha", raw)
+	}
+	return v.String(), nil
+}
+
+func parseRef(s string) (Value, error) {
+	end := strings.Index(s, "}")
+	if end < 0 || end != len(s)-1 {
+		return Value{}, fmt.Errorf("invalid color reference %q", s)
+	}
+	body := s[2:end]
+	if body == "" {
+		return Value{}, fmt.Errorf("empty color reference %q", s)
+	}
+
+	v := Value{Ref: s}
+	// ${xrdb:name:fallback} or ${ENV_VAR:fallback}
+	name, fallback, hasFallback := strings.Cut(strings.TrimPrefix(body, "xrdb:"), ":")
+	if name == "" {
+		return Value{}, fmt.Errorf("color reference %q has no name", s)
+	}
+	if hasFallback {
+		fb, err := Parse(fallback)
+		if err != nil || fb.IsRef() {
+			return Value{}, fmt.Errorf("invalid fallback in color reference %q", s)
+		}
+		v.Fallback = &fb.Color
+	}
+	return v, nil
+}
+
+func parseHex(s string) (Color, error) {
+	s = strings.TrimPrefix(s, "#")
+	switch len(s) {
+	case 3, 4:
+		expanded := make([]byte, 0, len(s)*2)
+		for i := 0; i < len(s); i++ {
+			expanded = append(expanded, s[i], s[i])
+		}
+		s = string(expanded)
+	case 6, 8:
+	default:
+		return Color{}, fmt.Errorf("hex colors need 3, 4, 6 or 8 digits")
+	}
+
+	n, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return Color{}, fmt.Errorf("not a hex number")
+	}
+	if len(s) == 6 {
+		return Color{R: uint8(n >> 16), G: uint8(n >> 8), B: uint8(n), A: 255}, nil
+	}
+	return Color{R: uint8(n >> 24), G: uint8(n >> 16), B: uint8(n >> 8), A: uint8(n)}, nil
+}
+
+// funcArgs splits the arguments of name(...) in either the legacy comma
+// syntax or the space syntax with an optional "/ alpha".
+func funcArgs(s string) ([]string, error) {
+	open := strings.Index(s, "(")
+	if open < 0 || !strings.HasSuffix(s, ")") {
+		return nil, fmt.Errorf("missing parentheses")
+	}
+	body := strings.TrimSpace(s[open+1 : len(s)-1])
+	if strings.Contains(body, ",") {
+		args := strings.Split(body, ",")
+		for i := range args {
+			args[i] = strings.TrimSpace(args[i])
+		}
+		return args, nil
+	}
+	body = strings.Replace(body, "/", " ", 1)
+	return strings.Fields(body), nil
+}
+
+func parseRGBFunc(s string) (Color, error) {
+	args, err := funcArgs(s)
+	if err != nil {
+		return Color{}, err
+	}
+	return parseRGBArgs(args)
+}
+
+func parseRGBArgs(args []string) (Color, error) {
+	if len(args) != 3 && len(args) != 4 {
+		return Color{}, fmt.Errorf("expected 3 or 4 components, got %d", len(args))
+	}
+	var channels [3]uint8
+	for i := range 3 {
+		v, err := parseComponent(args[i], 255)
+		if err != nil {
+			return Color{}, err
+		}
+		channels[i] = v
+	}
+	c := Color{R: channels[0], G: channels[1], B: channels[2], A: 255}
+	if len(args) == 4 {
+		a, err := parseAlpha(args[3])
+		if err != nil {
+			return Color{}, err
+		}
+		c.A = a
+	}
+	return c, nil
+}
+
+func parseHSLFunc(s string) (Color, error) {
+	args, err := funcArgs(s)
+	if err != nil {
+		return Color{}, err
+	}
+	if len(args) != 3 && len(args) != 4 {
+		return Color{}, fmt.Errorf("expected 3 or 4 components, got %d", len(args))
+	}
+
+	h, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(args[0]), "deg"), 64)
+	if err != nil {
+		return Color{}, fmt.Errorf("invalid hue %q", args[0])
+	}
+	sat, err := parsePercent(args[1])
+	if err != nil {
+		return Color{}, err
+	}
+	light, err := parsePercent(args[2])
+	if err != nil {
+		return Color{}, err
+	}
+
+	c := FromHSL(h, sat, light)
+	if len(args) == 4 {
+		a, err := parseAlpha(args[3])
+		if err != nil {
+			return Color{}, err
+		}
+		c.A = a
+	}
+	return c, nil
+}
+
+// parseComponent reads an integer channel or a percentage of max.
+func parseComponent(s string, max float64) (uint8, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, "%") {
+		p, err := parsePercent(s)
+		if err != nil {
+			return 0, err
+		}
+		return clampByte(p * max), nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 || v > max {
+		return 0, fmt.Errorf("component %q out of range 0-%v", s, max)
+	}
+	return clampByte(v), nil
+}
+
+func parseAlpha(s string) (uint8, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, "%") {
+		p, err := parsePercent(s)
+		if err != nil {
+			return 0, err
+		}
+		return clampByte(p * 255), nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 || v > 1 {
+		return 0, fmt.Errorf("alpha %q out of range 0-1", s)
+	}
+	return clampByte(v * 255), nil
+}
+
+// parsePercent returns a percentage as a 0-1 fraction.
+func parsePercent(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil || !strings.HasSuffix(s, "%") || v < 0 || v > 100 {
+		return 0, fmt.Errorf("percentage %q out of range 0%%-100%%", s)
+	}
+	return v / 100, nil
+}
+
+func clampByte(v float64) uint8 {
+	return uint8(math.Round(math.Max(0, math.Min(255, v))))
+}
+
+// FromHSL converts hue in degrees and saturation/lightness in 0-1 to an
+// opaque color.
+func FromHSL(h, s, l float64) Color {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	chroma := (1 - math.Abs(2*l-1)) * s
+	x := chroma * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - chroma/2
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = chroma, x, 0
+	case h < 120:
+		r, g, b = x, chroma, 0
+	case h < 180:
+		r, g, b = 0, chroma, x
+	case h < 240:
+		r, g, b = 0, x, chroma
+	case h < 300:
+		r, g, b = x, 0, chroma
+	default:
+		r, g, b = chroma, 0, x
+	}
+	return Color{R: clampByte((r + m) * 255), G: clampByte((g + m) * 255), B: clampByte((b + m) * 255), A: 255}
+}
@@ -0,0 +1,129 @@
+package spicecolor
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string // canonical form
+	}{
+		{"ff0000", "ff0000"},
+		{"#FF0000", "ff0000"},
+		{"  #1db954  ", "1db954"},
+		{"fff", "ffffff"},
+		{"#1234", "11223344"},
+		{"#ff000080", "ff000080"},
+		{"#ff0000ff", "ff0000"},
+		{"rgb(255, 128, 0)", "ff8000"},
+		{"rgb(255 128 0)", "ff8000"},
+		{"RGB(100%, 0%, 50%)", "ff0080"},
+		{"rgba(0, 0, 0, 0.5)", "00000080"},
+		{"rgb(255 0 0 / 50%)", "ff000080"},
+		{"255,128,0", "ff8000"},
+		{"hsl(0, 100%, 50%)", "ff0000"},
+		{"hsl(120 100% 25%)", "008000"},
+		{"hsl(240deg, 100%, 50%)", "0000ff"},
+		{"hsl(-120, 100%, 50%)", "0000ff"},
+		{"hsla(0, 0%, 100%, 0.5)", "ffffff80"},
+		{"${xrdb:color1}", "${xrdb:color1}"},
+		{"${SPICE_MAIN:1e1e2e}", "${SPICE_MAIN:1e1e2e}"},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.in)
+		if err != nil {
+			t.Errorf("Normalize(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		// The canonical form is what color.ini holds; reading it back must
+		// not change it.
+		if again, err := Normalize(got); err != nil || again != got {
+			t.Errorf("Normalize(%q) = %q, %v; not stable", got, again, err)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"   ",
+		"#12",
+		"#12345",
+		"#1234567",
+		"gggggg",
+		"#ff00zz",
+		"rgb(1, 2)",
+		"rgb(1, 2, 3, 4, 5)",
+		"rgb(256, 0, 0)",
+		"rgb(-1, 0, 0)",
+		"rgb(1, 2, 3",
+		"rgba(0, 0, 0, 2)",
+		"rgb(101%, 0%, 0%)",
+		"hsl(0, 100, 50%)",
+		"hsl(red, 100%, 50%)",
+		"1,2",
+		"${}",
+		"${xrdb:}",
+		"${:ff0000}",
+		"${FOO}x",
+		"${FOO:zz}",
+		"${FOO:${BAR}}",
+	} {
+		if v, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) = %+v, want an error", in, v)
+		}
+	}
+}
+
+func TestParseRef(t *testing.T) {
+	v, err := Parse("${xrdb:color1:#ff0000}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.IsRef() || v.Ref != "${xrdb:color1:#ff0000}" {
+		t.Errorf("Ref = %q, IsRef = %v", v.Ref, v.IsRef())
+	}
+	if c, ok := v.Resolved(); !ok || c != (Color{R: 255, A: 255}) {
+		t.Errorf("Resolved() = %+v, %v; want the fallback", c, ok)
+	}
+
+	v, err = Parse("${SPICE_TEXT}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v.Resolved(); ok || v.Fallback != nil {
+		t.Error("a reference without fallback resolved")
+	}
+
+	v, _ = Parse("1db954")
+	if c, ok := v.Resolved(); !ok || c != (Color{R: 0x1d, G: 0xb9, B: 0x54, A: 255}) {
+		t.Errorf("Resolved() = %+v, %v", c, ok)
+	}
+}
+
+func TestNormalizeOpaque(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "#FF0000", want: "ff0000"},
+		{in: "rgb(1, 2, 3)", want: "010203"},
+		{in: "#ff0000ff", want: "ff0000"},
+		{in: "${xrdb:color1}", want: "${xrdb:color1}"},
+		{in: "${xrdb:color1:ff0000}", want: "${xrdb:color1:ff0000}"},
+		{in: "#ff000080", wantErr: true},
+		{in: "rgba(0, 0, 0, 0.5)", wantErr: true},
+		{in: "#0000", wantErr: true},
+		{in: "${xrdb:color1:ff000080}", wantErr: true},
+		{in: "nope", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeOpaque(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("NormalizeOpaque(%q) = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
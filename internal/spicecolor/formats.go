@@ -0,0 +1,424 @@
+package spicecolor
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"manager/internal/spiceconfig"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Palette formats a color.ini scheme can be converted from and to. Each
+// format names its colors with its own slots (base00, color4, background,
+// ...); a mapping from spicetify keys to slots connects the two.
+const (
+	FormatBase16     = "base16"
+	FormatBase24     = "base24"
+	FormatPywal      = "pywal"
+	FormatXresources = "xresources"
+	FormatCSS        = "css"
+	FormatCompact    = "compact"
+)
+
+var Formats = []string{FormatBase16, FormatBase24, FormatPywal, FormatXresources, FormatCSS, FormatCompact}
+
+// Palette is a set of colors keyed by format slot.
+type Palette map[string]Color
+
+// spicetifyKeys is the key order used by the compact format.
+var spicetifyKeys = spiceconfig.StandardColorKeys
+
+func base16Slots(n int) []string {
+	slots := make([]string, n)
+	for i := range slots {
+		slots[i] = fmt.Sprintf("base%02X", i)
+	}
+	return slots
+}
+
+var terminalSlots = func() []string {
+	slots := []string{"background", "foreground", "cursor"}
+	for i := range 16 {
+		slots = append(slots, fmt.Sprintf("color%d", i))
+	}
+	return slots
+}()
+
+// formatSlots lists the slots a format writes on export, in output order.
+// CSS and compact use spicetify keys as slots.
+func formatSlots(format string) []string {
+	switch format {
+	case FormatBase16:
+		return base16Slots(16)
+	case FormatBase24:
+		return base16Slots(24)
+	case FormatPywal, FormatXresources:
+		return terminalSlots
+	}
+	return spicetifyKeys
+}
+
+// DefaultMapping returns how a format's slots map onto spicetify keys when
+// importing: spicetify key -> slot.
+func DefaultMapping(format string) map[string]string {
+	switch format {
+	case FormatBase16, FormatBase24:
+		return map[string]string{
+			"text": "base05", "subtext": "base04",
+			"main": "base00", "main-elevated": "base01",
+			"highlight": "base02", "highlight-elevated": "base03",
+			"sidebar": "base01", "player": "base01", "card": "base01", "shadow": "base00",
+			"selected-row": "base04", "button": "base0D", "button-active": "base0C",
+			"button-disabled": "base03", "tab-active": "base02",
+			"notification": "base0D", "notification-error": "base08", "misc": "base03",
+		}
+	case FormatPywal, FormatXresources:
+		return map[string]string{
+			"text": "foreground", "subtext": "color7",
+			"main": "background", "main-elevated": "color0",
+			"highlight": "color0", "highlight-elevated": "color8",
+			"sidebar": "background", "player": "background", "card": "color0", "shadow": "background",
+			"selected-row": "color7", "button": "color4", "button-active": "color12",
+			"button-disabled": "color8", "tab-active": "color8",
+			"notification": "color4", "notification-error": "color1", "misc": "color8",
+		}
+	}
+	mapping := map[string]string{}
+	for _, key := range spicetifyKeys {
+		mapping[key] = key
+	}
+	return mapping
+}
+
+// exportFallback fills the slots an import mapping does not reach, so an
+// exported base16 or terminal palette is always complete.
+var exportFallback = map[string]string{
+	"base00": "main", "base01": "sidebar", "base02": "highlight", "base03": "button-disabled",
+	"base04": "subtext", "base05": "text", "base06": "text", "base07": "text",
+	"base08": "notification-error", "base09": "misc", "base0A": "button-active", "base0B": "button",
+	"base0C": "button-active", "base0D": "button", "base0E": "notification", "base0F": "misc",
+	"base10": "shadow", "base11": "shadow", "base12": "notification-error", "base13": "button-active",
+	"base14": "button", "base15": "button-active", "base16": "button", "base17": "notification",
+
+	"background": "main", "foreground": "text", "cursor": "text",
+	"color0": "main-elevated", "color1": "notification-error", "color2": "button", "color3": "button-active",
+	"color4": "button", "color5": "notification", "color6": "button-active", "color7": "subtext",
+	"color8": "button-disabled", "color9": "notification-error", "color10": "button", "color11": "button-active",
+	"color12": "button-active", "color13": "notification", "color14": "button-active", "color15": "text",
+}
+
+// ToScheme maps a palette onto spicetify keys. Keys whose slot is missing
+// from the palette are left out.
+func ToScheme(p Palette, mapping map[string]string) map[string]Color {
+	scheme := map[string]Color{}
+	for key, slot := range mapping {
+		if c, ok := p[slot]; ok {
+			scheme[key] = c
+		}
+	}
+	return scheme
+}
+
+// FromScheme builds a format's palette from a scheme. Slots named in
+// mapping take that key's color; the rest fall back to sensible defaults.
+func FromScheme(format string, scheme map[string]Color, mapping map[string]string) Palette {
+	slotKey := map[string]string{}
+	for _, key := range slices.Sorted(maps.Keys(mapping)) {
+		if _, taken := slotKey[mapping[key]]; !taken {
+			slotKey[mapping[key]] = key
+		}
+	}
+
+	p := Palette{}
+	if format == FormatCSS {
+		// CSS can carry any key, not just the standard ones.
+		for key, c := range scheme {
+			p[key] = c
+		}
+	}
+	for _, slot := range formatSlots(format) {
+		// Several keys usually share a slot; the fallback key is the most
+		// representative one whenever the mapping agrees with it.
+		fallback := exportFallback[slot]
+		candidates := []string{slotKey[slot], fallback, slot}
+		if mapping[fallback] == slot {
+			candidates = []string{fallback, slotKey[slot], slot}
+		}
+		for _, key := range candidates {
+			if c, ok := scheme[key]; ok && key != "" {
+				p[slot] = c
+				break
+			}
+		}
+	}
+	return p
+}
+
+// Decode parses data in the given format. name is the scheme name stored in
+// the file, if the format has one.
+func Decode(format, data string) (p Palette, name string, err error) {
+	switch format {
+	case FormatBase16, FormatBase24:
+		return decodeBase16(data)
+	case FormatPywal:
+		p, err = decodePywal(data)
+	case FormatXresources:
+		p, err = decodeXresources(data)
+	case FormatCSS:
+		p, err = decodeCSS(data)
+	case FormatCompact:
+		p, name, err = decodeCompact(data)
+	default:
+		return nil, "", fmt.Errorf("unknown palette format %q", format)
+	}
+	if err == nil && len(p) == 0 {
+		err = fmt.Errorf("no colors found in %s data", format)
+	}
+	return p, name, err
+}
+
+// Encode renders a palette built by FromScheme in the given format.
+func Encode(format, name string, p Palette) (string, error) {
+	var b strings.Builder
+	slots := formatSlots(format)
+
+	switch format {
+	case FormatBase16, FormatBase24:
+		fmt.Fprintf(&b, "scheme: %q\nauthor: \"SpicetifyX\"\n", name)
+		for _, slot := range slots {
+			if c, ok := p[slot]; ok {
+				fmt.Fprintf(&b, "%s: \"%s\"\n", slot, c.Hex()[:6])
+			}
+		}
+
+	case FormatPywal:
+		out := struct {
+			Special map[string]string `json:"special"`
+			Colors  map[string]string `json:"colors"`
+		}{map[string]string{}, map[string]string{}}
+		for _, slot := range slots {
+			c, ok := p[slot]
+			if !ok {
+				continue
+			}
+			if strings.HasPrefix(slot, "color") {
+				out.Colors[slot] = "#" + c.Hex()[:6]
+			} else {
+				out.Special[slot] = "#" + c.Hex()[:6]
+			}
+		}
+		data, err := json.MarshalIndent(out, "", "    ")
+		if err != nil {
+			return "", err
+		}
+		b.Write(data)
+		b.WriteString("\n")
+
+	case FormatXresources:
+		fmt.Fprintf(&b, "! %s\n", name)
+		for _, slot := range slots {
+			if c, ok := p[slot]; ok {
+				if slot == "cursor" {
+					slot = "cursorColor"
+				}
+				fmt.Fprintf(&b, "*.%s: #%s\n", slot, c.Hex()[:6])
+			}
+		}
+
+	case FormatCSS:
+		fmt.Fprintf(&b, "/* %s */\n:root {\n", name)
+		keys := sortedSchemeKeys(p)
+		for _, key := range keys {
+			fmt.Fprintf(&b, "  --spice-%s: #%s;\n", key, p[key].Hex())
+		}
+		for _, key := range keys {
+			c := p[key]
+			fmt.Fprintf(&b, "  --spice-rgb-%s: %d,%d,%d;\n", key, c.R, c.G, c.B)
+		}
+		b.WriteString("}\n")
+
+	case FormatCompact:
+		return encodeCompact(name, p), nil
+
+	default:
+		return "", fmt.Errorf("unknown palette format %q", format)
+	}
+	return b.String(), nil
+}
+
+// sortedSchemeKeys orders the standard keys first, then any extras by name.
+func sortedSchemeKeys(p Palette) []string {
+	var keys []string
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		ii, ij := slices.Index(spicetifyKeys, keys[i]), slices.Index(spicetifyKeys, keys[j])
+		switch {
+		case ii >= 0 && ij >= 0:
+			return ii < ij
+		case ii >= 0 || ij >= 0:
+			return ii >= 0
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+var (
+	base16Line = regexp.MustCompile(`^\s*(base[0-9A-Fa-f]{2})\s*:\s*["']?#?([0-9A-Fa-f]{6})["']?\s*(?:#.*)?$`)
+	yamlName   = regexp.MustCompile(`^\s*(?:scheme|name)\s*:\s*["']?(.*?)["']?\s*$`)
+)
+
+// decodeBase16 understands both the classic flat layout and the newer one
+// that nests the colors under "palette:"; it is not a general YAML parser.
+func decodeBase16(data string) (Palette, string, error) {
+	p := Palette{}
+	name := ""
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := base16Line.FindStringSubmatch(line); m != nil {
+			c, err := parseHex(strings.ToLower(m[2]))
+			if err != nil {
+				return nil, "", err
+			}
+			p["base"+strings.ToUpper(m[1][4:])] = c
+			continue
+		}
+		if m := yamlName.FindStringSubmatch(line); m != nil && name == "" {
+			name = m[1]
+		}
+	}
+	if len(p) == 0 {
+		return nil, "", fmt.Errorf("no baseXX colors found")
+	}
+	return p, name, nil
+}
+
+func decodePywal(data string) (Palette, error) {
+	var in struct {
+		Special map[string]string `json:"special"`
+		Colors  map[string]string `json:"colors"`
+	}
+	if err := json.Unmarshal([]byte(data), &in); err != nil {
+		return nil, fmt.Errorf("invalid pywal colors.json: %w", err)
+	}
+	p := Palette{}
+	for _, group := range []map[string]string{in.Special, in.Colors} {
+		for slot, raw := range group {
+			v, err := Parse(raw)
+			if err != nil || v.IsRef() {
+				return nil, fmt.Errorf("pywal %s: invalid color %q", slot, raw)
+			}
+			p[slot] = v.Color
+		}
+	}
+	return p, nil
+}
+
+var xresourcesLine = regexp.MustCompile(`^\s*[\w.*-]*?[*.]?(background|foreground|cursorColor|color\d{1,2})\s*:\s*(\S+)`)
+
+func decodeXresources(data string) (Palette, error) {
+	p := Palette{}
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "!") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m := xresourcesLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		v, err := Parse(m[2])
+		if err != nil || v.IsRef() {
+			return nil, fmt.Errorf("xresources %s: invalid color %q", m[1], m[2])
+		}
+		slot := m[1]
+		if slot == "cursorColor" {
+			slot = "cursor"
+		}
+		p[slot] = v.Color
+	}
+	return p, nil
+}
+
+var cssProperty = regexp.MustCompile(`--spice-([\w-]+)\s*:\s*([^;}]+)`)
+
+func decodeCSS(data string) (Palette, error) {
+	p := Palette{}
+	for _, m := range cssProperty.FindAllStringSubmatch(data, -1) {
+		if strings.HasPrefix(m[1], "rgb-") {
+			continue
+		}
+		v, err := Parse(m[2])
+		if err != nil || v.IsRef() {
+			return nil, fmt.Errorf("--spice-%s: invalid color %q", m[1], strings.TrimSpace(m[2]))
+		}
+		p[m[1]] = v.Color
+	}
+	return p, nil
+}
+
+// compactPrefix starts every share string. The payload is base64url of a
+// 3 byte bitmask saying which spicetifyKeys are present, followed by their
+// RGB values in that order; an optional name follows after a "~".
+const compactPrefix = "spx1:"
+
+func encodeCompact(name string, p Palette) string {
+	var mask uint32
+	var rgb []byte
+	for i, key := range spicetifyKeys {
+		if c, ok := p[key]; ok {
+			mask |= 1 << i
+			rgb = append(rgb, c.R, c.G, c.B)
+		}
+	}
+	payload := append([]byte{byte(mask >> 16), byte(mask >> 8), byte(mask)}, rgb...)
+	s := compactPrefix + base64.RawURLEncoding.EncodeToString(payload)
+	if name != "" {
+		s += "~" + base64.RawURLEncoding.EncodeToString([]byte(name))
+	}
+	return s
+}
+
+func decodeCompact(data string) (Palette, string, error) {
+	s, ok := strings.CutPrefix(strings.TrimSpace(data), compactPrefix)
+	if !ok {
+		return nil, "", fmt.Errorf("share strings start with %q", compactPrefix)
+	}
+	s, encodedName, _ := strings.Cut(s, "~")
+
+	payload, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(payload) < 3 {
+		return nil, "", fmt.Errorf("malformed share string")
+	}
+	mask := uint32(payload[0])<<16 | uint32(payload[1])<<8 | uint32(payload[2])
+	rgb := payload[3:]
+
+	p := Palette{}
+	for i, key := range spicetifyKeys {
+		if mask&(1<<i) == 0 {
+			continue
+		}
+		if len(rgb) < 3 {
+			return nil, "", fmt.Errorf("share string is truncated")
+		}
+		p[key] = Color{R: rgb[0], G: rgb[1], B: rgb[2], A: 255}
+		rgb = rgb[3:]
+	}
+
+	name := ""
+	if encodedName != "" {
+		if raw, err := base64.RawURLEncoding.DecodeString(encodedName); err == nil {
+			name = string(raw)
+		}
+	}
+	return p, name, nil
+}
@@ -0,0 +1,137 @@
+package spicecolor
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func mustColor(t *testing.T, hex string) Color {
+	t.Helper()
+	v, err := Parse(hex)
+	if err != nil || v.IsRef() {
+		t.Fatalf("bad test color %q: %v", hex, err)
+	}
+	return v.Color
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	tests := []struct {
+		format string
+		colors map[string]string
+	}{
+		{FormatCSS, map[string]string{"text": "ffffff", "main": "1e1e2e", "button": "1db954", "shadow": "00000080", "my-extra": "abcdef"}},
+		{FormatPywal, map[string]string{"background": "1e1e2e", "foreground": "cdd6f4", "cursor": "f5e0dc", "color0": "45475a", "color15": "a6adc8"}},
+		{FormatXresources, map[string]string{"background": "1e1e2e", "foreground": "cdd6f4", "cursor": "f5e0dc", "color1": "f38ba8", "color12": "89b4fa"}},
+		{FormatBase16, map[string]string{"base00": "1e1e2e", "base05": "cdd6f4", "base0D": "89b4fa"}},
+		{FormatBase24, map[string]string{"base00": "1e1e2e", "base17": "f2cdcd"}},
+		{FormatCompact, map[string]string{"text": "ffffff", "main": "121212", "misc": "7f7f7f"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			p := Palette{}
+			for slot, hex := range tt.colors {
+				p[slot] = mustColor(t, hex)
+			}
+			data, err := Encode(tt.format, "Mocha", p)
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			got, name, err := Decode(tt.format, data)
+			if err != nil {
+				t.Fatalf("Decode: %v\n%s", err, data)
+			}
+			if !maps.Equal(got, p) {
+				t.Errorf("round trip changed the palette\ngot:  %v\nwant: %v\n%s", got, p, data)
+			}
+			if (tt.format == FormatCompact || tt.format == FormatBase16 || tt.format == FormatBase24) && name != "Mocha" {
+				t.Errorf("name = %q, want Mocha", name)
+			}
+		})
+	}
+}
+
+func TestDecodeCSSSkipsRGBVariables(t *testing.T) {
+	p, _, err := Decode(FormatCSS, ":root { --spice-text: #FFFFFF; --spice-rgb-text: 255,255,255; --spice-main: rgb(18 18 18) }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Palette{"text": {255, 255, 255, 255}, "main": {18, 18, 18, 255}}
+	if !maps.Equal(p, want) {
+		t.Errorf("got %v, want %v", p, want)
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	tests := []struct{ format, data string }{
+		{FormatCSS, "body { color: red; }"},
+		{FormatCSS, ":root { --spice-text: nope; }"},
+		{FormatCSS, ":root { --spice-text: ${xrdb:color1}; }"},
+		{FormatPywal, "{not json"},
+		{FormatPywal, `{"colors": {"color0": "#12"}}`},
+		{FormatPywal, `{}`},
+		{FormatXresources, "! only a comment\n"},
+		{FormatBase16, "scheme: nothing\n"},
+		{FormatCompact, "AAAB____"},
+		{FormatCompact, "spx1:!!!"},
+		{FormatCompact, "spx1:AA"},
+		{FormatCompact, "spx1:AAAD____"},
+		{"nope", "anything"},
+	}
+	for _, tt := range tests {
+		if p, _, err := Decode(tt.format, tt.data); err == nil {
+			t.Errorf("Decode(%s, %q) = %v, want an error", tt.format, tt.data, p)
+		}
+	}
+}
+
+func TestCompactString(t *testing.T) {
+	tests := []struct {
+		name   string
+		colors map[string]string
+		want   string
+	}{
+		// Bit 0 is text; one RGB triple follows the three mask bytes.
+		{"", map[string]string{"text": "ffffff"}, "spx1:AAAB____"},
+		{"x", map[string]string{"text": "ffffff"}, "spx1:AAAB____~eA"},
+		// Bits 0 and 2 (text, main); keys outside the standard list and
+		// alpha are not part of the format.
+		{"", map[string]string{"main": "00000080", "text": "010203", "extra": "ffffff"}, "spx1:AAAFAQIDAAAA"},
+		{"", map[string]string{}, "spx1:AAAA"},
+	}
+	for _, tt := range tests {
+		p := Palette{}
+		for slot, hex := range tt.colors {
+			p[slot] = mustColor(t, hex)
+		}
+		got, err := Encode(FormatCompact, tt.name, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Encode(%v, %q) = %q, want %q", tt.colors, tt.name, got, tt.want)
+		}
+	}
+
+	// Every standard key fits in the 24-bit mask.
+	if len(spicetifyKeys) > 24 {
+		t.Errorf("%d standard keys do not fit the compact mask", len(spicetifyKeys))
+	}
+}
+
+func TestEncodeCSSOrder(t *testing.T) {
+	p := Palette{"zeta": {A: 255}, "main": {A: 255}, "text": {A: 255}, "alpha": {A: 255}}
+	data, err := Encode(FormatCSS, "x", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := []string{"--spice-text:", "--spice-main:", "--spice-alpha:", "--spice-zeta:", "--spice-rgb-text:"}
+	last := -1
+	for _, s := range order {
+		i := strings.Index(data, s)
+		if i < last {
+			t.Errorf("%s is out of order in\n%s", s, data)
+		}
+		last = i
+	}
+}
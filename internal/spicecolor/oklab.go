@@ -0,0 +1,124 @@
+package spicecolor
+
+import "math"
+
+// OKLab is Björn Ottosson's perceptual color space. L runs from 0 (black) to
+// 1 (white); equal distances look roughly equally different, which makes it
+// a good space to cluster and to nudge lightness in.
+type OKLab struct {
+	L, A, B float64
+}
+
+func srgbToLinear(c uint8) float64 {
+	v := float64(c) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) uint8 {
+	if v <= 0.0031308 {
+		v *= 12.92
+	} else {
+		v = 1.055*math.Pow(v, 1/2.4) - 0.055
+	}
+	return clampByte(v * 255)
+}
+
+func (c Color) OKLab() OKLab {
+	r, g, b := srgbToLinear(c.R), srgbToLinear(c.G), srgbToLinear(c.B)
+
+	l := math.Cbrt(0.4122214708*r + 0.5363325363*g + 0.0514459929*b)
+	m := math.Cbrt(0.2119034982*r + 0.6806995451*g + 0.1073969566*b)
+	s := math.Cbrt(0.0883024619*r + 0.2817188376*g + 0.6299787005*b)
+
+	return OKLab{
+		L: 0.2104542553*l + 0.7936177850*m - 0.0040720468*s,
+		A: 1.9779984951*l - 2.4285922050*m + 0.4505937099*s,
+		B: 0.0259040371*l + 0.7827717662*m - 0.8086757660*s,
+	}
+}
+
+// Color converts back to an opaque sRGB color, clipping out-of-gamut values.
+func (o OKLab) Color() Color {
+	l := o.L + 0.3963377774*o.A + 0.2158037573*o.B
+	m := o.L - 0.1055613458*o.A - 0.0638541728*o.B
+	s := o.L - 0.0894841775*o.A - 1.2914855480*o.B
+	l, m, s = l*l*l, m*m*m, s*s*s
+
+	return Color{
+		R: linearToSRGB(+4.0767416621*l - 3.3077115913*m + 0.2309699292*s),
+		G: linearToSRGB(-1.2684380046*l + 2.6097574011*m - 0.3413193965*s),
+		B: linearToSRGB(-0.0041960863*l - 0.7034186147*m + 1.7076147010*s),
+		A: 255,
+	}
+}
+
+func (o OKLab) Chroma() float64 {
+	return math.Hypot(o.A, o.B)
+}
+
+func (o OKLab) Distance(p OKLab) float64 {
+	return math.Sqrt((o.L-p.L)*(o.L-p.L) + (o.A-p.A)*(o.A-p.A) + (o.B-p.B)*(o.B-p.B))
+}
+
+// WithLightness keeps hue and chroma and replaces L.
+func (c Color) WithLightness(l float64) Color {
+	o := c.OKLab()
+	o.L = math.Max(0, math.Min(1, l))
+	return o.Color()
+}
+
+// Luminance is the WCAG relative luminance of c.
+func (c Color) Luminance() float64 {
+	return 0.2126*srgbToLinear(c.R) + 0.7152*srgbToLinear(c.G) + 0.0722*srgbToLinear(c.B)
+}
+
+// Contrast is the WCAG 2 contrast ratio between two colors, from 1 to 21.
+func Contrast(a, b Color) float64 {
+	la, lb := a.Luminance(), b.Luminance()
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// EnsureContrast returns fg with the smallest change in OKLab lightness that
+// reaches ratio against bg. If no lightness gets there, the best reachable
+// color is returned with ok false.
+func EnsureContrast(fg, bg Color, ratio float64) (Color, bool) {
+	fixed, ok := AdjustLightness(fg, func(c Color) bool { return Contrast(c, bg) >= ratio })
+	if ok {
+		return fixed, true
+	}
+	// Black or white, whichever gets closer.
+	best := fg.WithLightness(0)
+	if white := fg.WithLightness(1); Contrast(white, bg) > Contrast(best, bg) {
+		best = white
+	}
+	best.A = fg.A
+	return best, false
+}
+
+// AdjustLightness searches outwards from c's OKLab lightness, in both
+// directions, for the closest lightness that satisfies accept.
+func AdjustLightness(c Color, accept func(Color) bool) (Color, bool) {
+	if accept(c) {
+		return c, true
+	}
+	start := c.OKLab().L
+	for step := 0.005; step <= 1; step += 0.005 {
+		for _, l := range []float64{start + step, start - step} {
+			if l < 0 || l > 1 {
+				continue
+			}
+			candidate := c.WithLightness(l)
+			candidate.A = c.A
+			if accept(candidate) {
+				return candidate, true
+			}
+		}
+	}
+	return c, false
+}
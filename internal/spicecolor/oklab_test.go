@@ -0,0 +1,64 @@
+package spicecolor
+
+import (
+	"math"
+	"testing"
+)
+
+func TestContrast(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"000000", "ffffff", 21},
+		{"ffffff", "000000", 21},
+		{"ffffff", "ffffff", 1},
+		{"777777", "ffffff", 4.48},
+		{"767676", "ffffff", 4.54},
+		{"ff0000", "ffffff", 4.00},
+		{"0000ff", "ffffff", 8.59},
+		{"1db954", "121212", 7.24},
+	}
+	for _, tt := range tests {
+		got := Contrast(mustColor(t, tt.a), mustColor(t, tt.b))
+		if math.Abs(got-tt.want) > 0.01 {
+			t.Errorf("Contrast(%s, %s) = %.3f, want %.2f", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestOKLabRoundTrip(t *testing.T) {
+	for _, hex := range []string{"000000", "ffffff", "ff0000", "00ff00", "0000ff", "1db954", "7f7f7f", "123456"} {
+		c := mustColor(t, hex)
+		if got := c.OKLab().Color(); got != c {
+			t.Errorf("%s came back as %s", hex, got.Hex())
+		}
+	}
+	if l := mustColor(t, "ffffff").OKLab().L; math.Abs(l-1) > 1e-3 {
+		t.Errorf("white has L %v, want 1", l)
+	}
+}
+
+func TestEnsureContrast(t *testing.T) {
+	white := mustColor(t, "ffffff")
+	gray := mustColor(t, "999999")
+
+	fixed, ok := EnsureContrast(gray, white, 4.5)
+	if !ok || Contrast(fixed, white) < 4.5 {
+		t.Fatalf("EnsureContrast = %s (%.2f), %v", fixed.Hex(), Contrast(fixed, white), ok)
+	}
+	if fixed.OKLab().L >= gray.OKLab().L {
+		t.Errorf("fix %s should be darker than %s", fixed.Hex(), gray.Hex())
+	}
+	// The smallest change: a little lighter no longer passes.
+	if lighter := fixed.WithLightness(fixed.OKLab().L + 0.01); Contrast(lighter, white) >= 4.5 {
+		t.Errorf("fix %s is darker than it needs to be", fixed.Hex())
+	}
+
+	if same, ok := EnsureContrast(mustColor(t, "000000"), white, 4.5); !ok || same.Hex() != "000000" {
+		t.Errorf("a passing color was changed to %s", same.Hex())
+	}
+	if _, ok := EnsureContrast(gray, mustColor(t, "808080"), 25); ok {
+		t.Error("a ratio above 21 was reached")
+	}
+}
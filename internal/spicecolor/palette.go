@@ -0,0 +1,239 @@
+package spicecolor
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// Swatch is one palette entry; Weight is the share of sampled pixels that
+// fell into its cluster.
+type Swatch struct {
+	Color  Color
+	Weight float64
+}
+
+const (
+	maxPaletteSamples = 12000
+	kmeansIterations  = 20
+)
+
+// ExtractPalette clusters the pixels of img into at most k colors with
+// k-means in OKLab and returns them heaviest first. Large images are sampled
+// on a regular grid, transparent pixels are skipped.
+func ExtractPalette(img image.Image, k int) []Swatch {
+	points := samplePixels(img)
+	if len(points) == 0 || k <= 0 {
+		return nil
+	}
+	k = min(k, len(points))
+
+	centers := initCenters(points, k)
+	assign := make([]int, len(points))
+	for range kmeansIterations {
+		moved := false
+		for i, p := range points {
+			nearest := nearestCenter(p, centers)
+			if nearest != assign[i] {
+				assign[i] = nearest
+				moved = true
+			}
+		}
+
+		sums := make([]OKLab, k)
+		counts := make([]int, k)
+		for i, p := range points {
+			c := assign[i]
+			sums[c].L += p.L
+			sums[c].A += p.A
+			sums[c].B += p.B
+			counts[c]++
+		}
+		for c := range centers {
+			if counts[c] > 0 {
+				n := float64(counts[c])
+				centers[c] = OKLab{L: sums[c].L / n, A: sums[c].A / n, B: sums[c].B / n}
+			}
+		}
+		if !moved {
+			break
+		}
+	}
+
+	counts := make([]int, k)
+	for _, c := range assign {
+		counts[c]++
+	}
+	var swatches []Swatch
+	for c, center := range centers {
+		if counts[c] > 0 {
+			swatches = append(swatches, Swatch{
+				Color:  center.Color(),
+				Weight: float64(counts[c]) / float64(len(points)),
+			})
+		}
+	}
+	sort.SliceStable(swatches, func(i, j int) bool {
+		return swatches[i].Weight > swatches[j].Weight
+	})
+	return swatches
+}
+
+func samplePixels(img image.Image) []OKLab {
+	bounds := img.Bounds()
+	total := bounds.Dx() * bounds.Dy()
+	if total == 0 {
+		return nil
+	}
+	step := max(1, int(math.Sqrt(float64(total)/maxPaletteSamples)))
+
+	var points []OKLab
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a < 0x8000 {
+				continue
+			}
+			// RGBA is alpha-premultiplied; undo it for semi-transparent pixels.
+			c := Color{
+				R: uint8(r * 0xffff / a >> 8),
+				G: uint8(g * 0xffff / a >> 8),
+				B: uint8(b * 0xffff / a >> 8),
+				A: 255,
+			}
+			points = append(points, c.OKLab())
+		}
+	}
+	return points
+}
+
+// initCenters seeds k-means deterministically with farthest-point sampling,
+// starting from the mean color, so the same image always yields the same
+// palette.
+func initCenters(points []OKLab, k int) []OKLab {
+	var mean OKLab
+	for _, p := range points {
+		mean.L += p.L
+		mean.A += p.A
+		mean.B += p.B
+	}
+	n := float64(len(points))
+	mean = OKLab{L: mean.L / n, A: mean.A / n, B: mean.B / n}
+
+	centers := []OKLab{points[nearestCenter(mean, points)]}
+	dist := make([]float64, len(points))
+	for i, p := range points {
+		dist[i] = p.Distance(centers[0])
+	}
+	for len(centers) < k {
+		far := 0
+		for i := range points {
+			if dist[i] > dist[far] {
+				far = i
+			}
+		}
+		if dist[far] == 0 {
+			break
+		}
+		centers = append(centers, points[far])
+		for i, p := range points {
+			dist[i] = math.Min(dist[i], p.Distance(points[far]))
+		}
+	}
+	return centers
+}
+
+func nearestCenter(p OKLab, centers []OKLab) int {
+	best, bestDist := 0, math.Inf(1)
+	for i, c := range centers {
+		if d := p.Distance(c); d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// SchemeFromPalette maps a palette onto spicetify's standard color keys. The
+// heaviest swatch decides between a dark and a light scheme and tints the
+// backgrounds; the most colorful reasonably common swatch becomes the
+// accent. Foreground keys are pushed until they meet their contrast targets
+// against the backgrounds they are drawn on.
+func SchemeFromPalette(palette []Swatch) map[string]Color {
+	if len(palette) == 0 {
+		return nil
+	}
+
+	base := palette[0].Color.OKLab()
+	dark := base.L < 0.6
+	accent := pickAccent(palette)
+
+	// Backgrounds keep the hue of the dominant color with muted chroma.
+	tone := func(l, chromaScale float64) Color {
+		return OKLab{L: l, A: base.A * chromaScale, B: base.B * chromaScale}.Color()
+	}
+	lightness := func(darkL, lightL float64) float64 {
+		if dark {
+			return darkL
+		}
+		return lightL
+	}
+
+	main := tone(lightness(0.20, 0.97), 0.35)
+	scheme := map[string]Color{
+		"main":               main,
+		"main-elevated":      tone(lightness(0.25, 0.93), 0.35),
+		"highlight":          tone(lightness(0.28, 0.90), 0.35),
+		"highlight-elevated": tone(lightness(0.32, 0.87), 0.35),
+		"sidebar":            tone(lightness(0.16, 0.94), 0.35),
+		"player":             tone(lightness(0.18, 0.95), 0.35),
+		"card":               tone(lightness(0.26, 0.92), 0.35),
+		"shadow":             tone(lightness(0.08, 0.75), 0.2),
+		"tab-active":         tone(lightness(0.32, 0.86), 0.35),
+		"misc":               tone(lightness(0.55, 0.55), 0.3),
+		"text":               tone(lightness(0.96, 0.20), 0.1),
+		"subtext":            tone(lightness(0.78, 0.42), 0.15),
+		"selected-row":       tone(lightness(0.85, 0.35), 0.1),
+		"button-disabled":    tone(lightness(0.45, 0.70), 0.1),
+	}
+
+	accentOK := accent.OKLab()
+	button := OKLab{L: lightness(0.72, 0.50), A: accentOK.A, B: accentOK.B}.Color()
+	scheme["button"] = button
+	scheme["button-active"] = OKLab{L: lightness(0.80, 0.42), A: accentOK.A, B: accentOK.B}.Color()
+	scheme["notification"] = OKLab{L: lightness(0.62, 0.55), A: accentOK.A, B: accentOK.B}.Color()
+	scheme["notification-error"] = OKLab{L: lightness(0.63, 0.55), A: 0.19, B: 0.09}.Color()
+
+	targets := []struct {
+		fg, bg string
+		ratio  float64
+	}{
+		{"text", "main", 7},
+		{"text", "sidebar", 7},
+		{"subtext", "main", 4.5},
+		{"subtext", "sidebar", 4.5},
+		{"button", "player", 3},
+		{"button", "main", 3},
+		{"button-active", "main", 3},
+		{"notification", "main", 3},
+		{"notification-error", "main", 3},
+	}
+	for _, t := range targets {
+		scheme[t.fg], _ = EnsureContrast(scheme[t.fg], scheme[t.bg], t.ratio)
+	}
+	return scheme
+}
+
+// pickAccent prefers chroma but ignores specks that barely show in the image.
+func pickAccent(palette []Swatch) Color {
+	best, bestScore := palette[0].Color, -1.0
+	for _, s := range palette {
+		if s.Weight < 0.02 {
+			continue
+		}
+		score := s.Color.OKLab().Chroma() * math.Sqrt(s.Weight)
+		if score > bestScore {
+			best, bestScore = s.Color, score
+		}
+	}
+	return best
+}
@@ -0,0 +1,117 @@
+package spicecolor
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"testing"
+)
+
+// blocks returns a 30x20 image: 300 red, 200 blue and 100 near-white pixels,
+// plus a transparent column that must be ignored.
+func blocks() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 31, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 31; x++ {
+			var c color.NRGBA
+			switch {
+			case x == 30:
+				c = color.NRGBA{0, 255, 0, 0}
+			case x < 15:
+				c = color.NRGBA{200, 30, 40, 255}
+			case x < 25:
+				c = color.NRGBA{20, 40, 180, 255}
+			default:
+				c = color.NRGBA{240, 240, 235, 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestExtractPalette(t *testing.T) {
+	got := ExtractPalette(blocks(), 3)
+	want := []struct {
+		hex    string
+		weight float64
+	}{
+		{"c81e28", 0.5},
+		{"1428b4", 1.0 / 3},
+		{"f0f0eb", 1.0 / 6},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d swatches, want %d: %v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		c := mustColor(t, w.hex)
+		if d := got[i].Color.OKLab().Distance(c.OKLab()); d > 0.005 {
+			t.Errorf("swatch %d = %s, want %s", i, got[i].Color.Hex(), w.hex)
+		}
+		if math.Abs(got[i].Weight-w.weight) > 1e-9 {
+			t.Errorf("swatch %d weight = %v, want %v", i, got[i].Weight, w.weight)
+		}
+	}
+}
+
+func TestExtractPaletteIsDeterministic(t *testing.T) {
+	// A gradient gives k-means real choices to make.
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x * 4), uint8(y * 4), uint8((x + y) * 2), 255})
+		}
+	}
+	first := ExtractPalette(img, 6)
+	if len(first) != 6 {
+		t.Fatalf("got %d swatches, want 6", len(first))
+	}
+	for i := 0; i < 5; i++ {
+		if again := ExtractPalette(img, 6); !slices.Equal(again, first) {
+			t.Fatalf("run %d differs:\n%v\n%v", i, again, first)
+		}
+	}
+
+	total := 0.0
+	for i, s := range first {
+		total += s.Weight
+		if i > 0 && s.Weight > first[i-1].Weight {
+			t.Errorf("swatches are not heaviest first: %v", first)
+		}
+	}
+	if math.Abs(total-1) > 1e-9 {
+		t.Errorf("weights sum to %v, want 1", total)
+	}
+}
+
+func TestExtractPaletteEdgeCases(t *testing.T) {
+	if got := ExtractPalette(image.NewNRGBA(image.Rect(0, 0, 4, 4)), 3); got != nil {
+		t.Errorf("fully transparent image gave %v", got)
+	}
+	if got := ExtractPalette(blocks(), 0); got != nil {
+		t.Errorf("k = 0 gave %v", got)
+	}
+	solid := image.NewUniform(color.NRGBA{10, 20, 30, 255})
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.Set(x, y, solid.C)
+		}
+	}
+	if got := ExtractPalette(img, 5); len(got) != 1 || got[0].Color.Hex() != "0a141e" || got[0].Weight != 1 {
+		t.Errorf("single-color image gave %v", got)
+	}
+}
+
+func TestSchemeFromPaletteMeetsContrast(t *testing.T) {
+	scheme := SchemeFromPalette(ExtractPalette(blocks(), 5))
+	for _, key := range []string{"text", "subtext", "main", "button"} {
+		if _, ok := scheme[key]; !ok {
+			t.Errorf("scheme has no %s", key)
+		}
+	}
+	if r := Contrast(scheme["text"], scheme["main"]); r < 4.5 {
+		t.Errorf("text on main has contrast %.2f", r)
+	}
+}
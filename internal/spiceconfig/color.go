@@ -0,0 +1,42 @@
+package spiceconfig
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StandardColorKeys are the color.ini keys spicetify turns into
+// --spice-<key> variables, in the order the CLI documents them. The compact
+// share format encodes keys by their index here, so the list must only ever
+// be appended to, or existing share strings decode to the wrong keys.
+var StandardColorKeys = []string{
+	"text", "subtext", "main", "main-elevated", "highlight", "highlight-elevated",
+	"sidebar", "player", "card", "shadow", "selected-row",
+	"button", "button-active", "button-disabled", "tab-active",
+	"notification", "notification-error", "misc",
+}
+
+// ValidateSchemeName rejects names that would not survive being written as
+// a [section] header.
+func ValidateSchemeName(name string) error {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return fmt.Errorf("scheme name must not be empty")
+	case name != strings.TrimSpace(name):
+		return fmt.Errorf("scheme name %q must not start or end with whitespace", name)
+	case strings.ContainsAny(name, "[]\r\n"):
+		return fmt.Errorf("scheme name %q must not contain brackets or line breaks", name)
+	}
+	return nil
+}
+
+// ValidateColorKey rejects keys that would be parsed back differently.
+func ValidateColorKey(key string) error {
+	switch {
+	case key == "":
+		return fmt.Errorf("color key must not be empty")
+	case strings.ContainsAny(key, "=;#[] \t\r\n"):
+		return fmt.Errorf("color key %q must not contain whitespace, brackets, '=', ';' or '#'", key)
+	}
+	return nil
+}
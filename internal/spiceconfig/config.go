@@ -0,0 +1,159 @@
+package spiceconfig
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	SectionSetting           = "Setting"
+	SectionPreprocesses      = "Preprocesses"
+	SectionAdditionalOptions = "AdditionalOptions"
+	SectionPatch             = "Patch"
+	SectionBackup            = "Backup"
+)
+
+const ListSeparator = "|"
+
+// knownKeys maps every key the spicetify CLI writes to the section it lives
+// in, so keys missing from an old config land where the CLI would put them.
+var knownKeys = map[string]string{
+	"spotify_path":           SectionSetting,
+	"prefs_path":             SectionSetting,
+	"current_theme":          SectionSetting,
+	"color_scheme":           SectionSetting,
+	"inject_css":             SectionSetting,
+	"inject_theme_js":        SectionSetting,
+	"replace_colors":         SectionSetting,
+	"overwrite_assets":       SectionSetting,
+	"spotify_launch_flags":   SectionSetting,
+	"check_spicetify_update": SectionSetting,
+	"always_enable_devtools": SectionSetting,
+
+	"disable_sentry":     SectionPreprocesses,
+	"disable_ui_logging": SectionPreprocesses,
+	"remove_rtl_rule":    SectionPreprocesses,
+	"expose_apis":        SectionPreprocesses,
+
+	"extensions":            SectionAdditionalOptions,
+	"custom_apps":           SectionAdditionalOptions,
+	"sidebar_config":        SectionAdditionalOptions,
+	"home_config":           SectionAdditionalOptions,
+	"experimental_features": SectionAdditionalOptions,
+
+	"version": SectionBackup,
+	"with":    SectionBackup,
+}
+
+// listKeys are the keys `spicetify config` treats as "|"-separated lists:
+// a value appends an entry and a value ending in "-" removes it.
+var listKeys = []string{"extensions", "custom_apps", "spotify_launch_flags"}
+
+func IsListKey(key string) bool {
+	return slices.Contains(listKeys, key)
+}
+
+// KeySection returns the section key belongs in. Keys already present in the
+// file win over the built-in table.
+func (f *File) KeySection(key string) (string, bool) {
+	if section, _, ok := f.Lookup(key); ok {
+		return section, true
+	}
+	section, ok := knownKeys[key]
+	return section, ok
+}
+
+func (f *File) Value(key string) string {
+	_, v, _ := f.Lookup(key)
+	return v
+}
+
+func (f *File) SetValue(key, value string) error {
+	section, ok := f.KeySection(key)
+	if !ok {
+		return fmt.Errorf("unknown config key %q", key)
+	}
+	f.Set(section, key, value)
+	return nil
+}
+
+func (f *File) Bool(key string) bool {
+	v := strings.TrimSpace(f.Value(key))
+	return v == "1" || strings.EqualFold(v, "true")
+}
+
+func (f *File) SetBool(key string, value bool) error {
+	if value {
+		return f.SetValue(key, "1")
+	}
+	return f.SetValue(key, "0")
+}
+
+func (f *File) List(key string) []string {
+	return SplitList(f.Value(key))
+}
+
+func (f *File) SetList(key string, values []string) error {
+	return f.SetValue(key, JoinList(values))
+}
+
+func SplitList(value string) []string {
+	values := []string{}
+	for _, v := range strings.Split(value, ListSeparator) {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func JoinList(values []string) string {
+	return strings.Join(values, ListSeparator)
+}
+
+func (f *File) CurrentTheme() string { return f.Value("current_theme") }
+
+func (f *File) SetCurrentTheme(theme string) error { return f.SetValue("current_theme", theme) }
+
+func (f *File) ColorScheme() string { return f.Value("color_scheme") }
+
+func (f *File) SetColorScheme(scheme string) error { return f.SetValue("color_scheme", scheme) }
+
+func (f *File) Extensions() []string { return f.List("extensions") }
+
+func (f *File) SetExtensions(extensions []string) error { return f.SetList("extensions", extensions) }
+
+func (f *File) CustomApps() []string { return f.List("custom_apps") }
+
+func (f *File) SetCustomApps(apps []string) error { return f.SetList("custom_apps", apps) }
+
+// ApplyCLI applies `spicetify config` arguments (key/value pairs) to the file
+// with the same semantics as the CLI, so changes can be written directly when
+// the binary is unavailable.
+func (f *File) ApplyCLI(args []string) error {
+	if len(args)%2 != 0 {
+		return fmt.Errorf("config arguments must be key/value pairs, got %d values", len(args))
+	}
+	for i := 0; i < len(args); i += 2 {
+		key, value := args[i], args[i+1]
+		if !IsListKey(key) {
+			if err := f.SetValue(key, value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		list := f.List(key)
+		if entry, remove := strings.CutSuffix(value, "-"); remove {
+			list = slices.DeleteFunc(list, func(v string) bool { return v == entry })
+		} else if value != "" && !slices.Contains(list, value) {
+			list = append(list, value)
+		}
+		if err := f.SetList(key, list); err != nil {
+			return err
+		}
+	}
+	return nil
+}
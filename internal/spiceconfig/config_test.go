@@ -0,0 +1,139 @@
+package spiceconfig
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestApplyCLI(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		extensions []string
+		customApps []string
+	}{
+		{
+			name:       "append extension",
+			args:       []string{"extensions", "keyboardShortcut.js"},
+			extensions: []string{"fullAppDisplay.js", "shuffle+.js", "keyboardShortcut.js"},
+			customApps: []string{"marketplace"},
+		},
+		{
+			name:       "append existing extension is a no-op",
+			args:       []string{"extensions", "shuffle+.js"},
+			extensions: []string{"fullAppDisplay.js", "shuffle+.js"},
+			customApps: []string{"marketplace"},
+		},
+		{
+			name:       "remove extension",
+			args:       []string{"extensions", "fullAppDisplay.js-"},
+			extensions: []string{"shuffle+.js"},
+			customApps: []string{"marketplace"},
+		},
+		{
+			name:       "remove missing extension is a no-op",
+			args:       []string{"extensions", "nope.js-"},
+			extensions: []string{"fullAppDisplay.js", "shuffle+.js"},
+			customApps: []string{"marketplace"},
+		},
+		{
+			name:       "custom apps add and remove",
+			args:       []string{"custom_apps", "lyrics-plus", "custom_apps", "marketplace-"},
+			extensions: []string{"fullAppDisplay.js", "shuffle+.js"},
+			customApps: []string{"lyrics-plus"},
+		},
+		{
+			name:       "remove then re-add moves to the end",
+			args:       []string{"extensions", "fullAppDisplay.js-", "extensions", "fullAppDisplay.js"},
+			extensions: []string{"shuffle+.js", "fullAppDisplay.js"},
+			customApps: []string{"marketplace"},
+		},
+		{
+			name:       "empty value leaves the list alone",
+			args:       []string{"extensions", ""},
+			extensions: []string{"fullAppDisplay.js", "shuffle+.js"},
+			customApps: []string{"marketplace"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Parse([]byte(sampleConfig))
+			if err := f.ApplyCLI(tt.args); err != nil {
+				t.Fatalf("ApplyCLI: %v", err)
+			}
+			if got := f.Extensions(); !slices.Equal(got, tt.extensions) {
+				t.Errorf("extensions = %v, want %v", got, tt.extensions)
+			}
+			if got := f.CustomApps(); !slices.Equal(got, tt.customApps) {
+				t.Errorf("custom_apps = %v, want %v", got, tt.customApps)
+			}
+		})
+	}
+}
+
+func TestApplyCLIKeepsLayout(t *testing.T) {
+	f := Parse([]byte(sampleConfig))
+	if err := f.ApplyCLI([]string{"extensions", "fullAppDisplay.js-", "current_theme", "Dribbblish"}); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.NewReplacer(
+		"extensions            = fullAppDisplay.js|shuffle+.js", "extensions            = shuffle+.js",
+		"current_theme           = Sleek ; picked in March", "current_theme           = Dribbblish ; picked in March",
+	).Replace(sampleConfig)
+	if got := string(f.Bytes()); got != want {
+		t.Errorf("got:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestApplyCLIErrors(t *testing.T) {
+	f := Parse([]byte(sampleConfig))
+	if err := f.ApplyCLI([]string{"extensions"}); err == nil {
+		t.Error("odd argument count accepted")
+	}
+	if err := f.ApplyCLI([]string{"no_such_key", "1"}); err == nil {
+		t.Error("unknown key accepted")
+	}
+}
+
+func TestSetValueUsesKnownSection(t *testing.T) {
+	f := Parse([]byte("[Setting]\ncurrent_theme = Sleek\n"))
+	if err := f.SetBool("expose_apis", true); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := f.Get(SectionPreprocesses, "expose_apis"); !ok || got != "1" {
+		t.Errorf("expose_apis = %q, %v; want 1 in [Preprocesses]", got, ok)
+	}
+	if !f.Bool("expose_apis") {
+		t.Error("Bool(expose_apis) = false")
+	}
+	want := "[Setting]\ncurrent_theme = Sleek\n\n[Preprocesses]\nexpose_apis = 1\n"
+	if got := string(f.Bytes()); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	tests := map[string][]string{
+		"":            {},
+		"a.js":        {"a.js"},
+		"a.js|b.js":   {"a.js", "b.js"},
+		" a.js | |b ": {"a.js", "b"},
+		"|a.js|":      {"a.js"},
+	}
+	for in, want := range tests {
+		if got := SplitList(in); !slices.Equal(got, want) {
+			t.Errorf("SplitList(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestApplyCLILaunchFlags(t *testing.T) {
+	f := Parse([]byte("[AdditionalOptions]\nspotify_launch_flags = --a|--b\n"))
+	if err := f.ApplyCLI([]string{"spotify_launch_flags", "--a-", "spotify_launch_flags", "--c=1"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.List("spotify_launch_flags"), []string{"--b", "--c=1"}; !slices.Equal(got, want) {
+		t.Errorf("spotify_launch_flags = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,436 @@
+// Package spiceconfig reads and writes Spicetify's INI files (config-xpui.ini
+// and theme color.ini) without losing anything the user wrote by hand.
+// Comments, blank lines, key alignment, ordering, a UTF-8 BOM and CRLF line
+// endings all survive a Parse/Bytes round trip; only lines whose value was
+// changed are re-rendered.
+package spiceconfig
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const bom = "\ufeff"
+
+type lineKind int
+
+const (
+	lineBlank lineKind = iota
+	lineComment
+	lineKey
+	lineOther
+)
+
+type line struct {
+	kind lineKind
+	raw  string
+
+	// Only set for lineKey. prefix is everything up to the start of the
+	// value (key, padding, "=" and following spaces); comment is an inline
+	// comment including the whitespace that separated it from the value.
+	key     string
+	value   string
+	prefix  string
+	comment string
+}
+
+func (l *line) render() string {
+	if l.kind != lineKey {
+		return l.raw
+	}
+	return l.prefix + l.value + l.comment
+}
+
+type Section struct {
+	name   string
+	header string
+	lines  []*line
+}
+
+type File struct {
+	bom      bool
+	newline  string
+	trailing bool
+	preamble []*line
+	sections []*Section
+}
+
+// Parse never fails: lines it does not understand are kept verbatim.
+func Parse(data []byte) *File {
+	f := &File{newline: "\n"}
+
+	if bytes.HasPrefix(data, []byte(bom)) {
+		f.bom = true
+		data = data[len(bom):]
+	}
+	content := string(data)
+	if strings.Contains(content, "\r\n") {
+		f.newline = "\r\n"
+	}
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	if content == "" {
+		return f
+	}
+	if strings.HasSuffix(content, "\n") {
+		f.trailing = true
+		content = strings.TrimSuffix(content, "\n")
+	}
+
+	var current *Section
+	for _, raw := range strings.Split(content, "\n") {
+		if name, ok := parseHeader(raw); ok {
+			current = &Section{name: name, header: raw}
+			f.sections = append(f.sections, current)
+			continue
+		}
+		l := parseLine(raw)
+		if current == nil {
+			f.preamble = append(f.preamble, l)
+		} else {
+			current.lines = append(current.lines, l)
+		}
+	}
+
+	return f
+}
+
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data), nil
+}
+
+func parseHeader(raw string) (string, bool) {
+	trimmed := strings.TrimSpace(raw)
+	if !strings.HasPrefix(trimmed, "[") {
+		return "", false
+	}
+	end := strings.Index(trimmed, "]")
+	if end < 0 {
+		return "", false
+	}
+	// Anything after the closing bracket must be a comment.
+	if rest := strings.TrimSpace(trimmed[end+1:]); rest != "" && rest[0] != ';' && rest[0] != '#' {
+		return "", false
+	}
+	return strings.TrimSpace(trimmed[1:end]), true
+}
+
+func parseLine(raw string) *line {
+	trimmed := strings.TrimSpace(raw)
+	switch {
+	case trimmed == "":
+		return &line{kind: lineBlank, raw: raw}
+	case strings.HasPrefix(trimmed, ";") || strings.HasPrefix(trimmed, "#"):
+		return &line{kind: lineComment, raw: raw}
+	}
+
+	eq := strings.Index(raw, "=")
+	if eq < 0 {
+		return &line{kind: lineOther, raw: raw}
+	}
+	key := strings.TrimSpace(raw[:eq])
+	if key == "" {
+		return &line{kind: lineOther, raw: raw}
+	}
+
+	rest := raw[eq+1:]
+	valueStart := len(rest) - len(strings.TrimLeft(rest, " \t"))
+	body := rest[valueStart:]
+
+	value, comment := body, ""
+	if idx := inlineCommentIndex(body); idx >= 0 {
+		value, comment = body[:idx], body[idx:]
+		// Keep the separating whitespace with the comment.
+		trimmedValue := strings.TrimRight(value, " \t")
+		comment = value[len(trimmedValue):] + comment
+		value = trimmedValue
+	} else {
+		trimmedValue := strings.TrimRight(value, " \t")
+		comment = value[len(trimmedValue):]
+		value = trimmedValue
+	}
+
+	return &line{
+		kind:    lineKey,
+		raw:     raw,
+		key:     key,
+		value:   value,
+		prefix:  raw[:eq+1] + rest[:valueStart],
+		comment: comment,
+	}
+}
+
+// inlineCommentIndex finds a ";" or "#" that starts an inline comment. The
+// marker has to be preceded by whitespace so values like "#1e1e2e" or
+// "a;b" are left intact.
+func inlineCommentIndex(body string) int {
+	for i := 1; i < len(body); i++ {
+		if (body[i] == ';' || body[i] == '#') && (body[i-1] == ' ' || body[i-1] == '\t') {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *File) Bytes() []byte {
+	var out []string
+	for _, l := range f.preamble {
+		out = append(out, l.render())
+	}
+	for _, s := range f.sections {
+		out = append(out, s.header)
+		for _, l := range s.lines {
+			out = append(out, l.render())
+		}
+	}
+
+	var buf bytes.Buffer
+	if f.bom {
+		buf.WriteString(bom)
+	}
+	buf.WriteString(strings.Join(out, f.newline))
+	if f.trailing && len(out) > 0 {
+		buf.WriteString(f.newline)
+	}
+	return buf.Bytes()
+}
+
+// Save writes the file through a temporary sibling and a rename so readers
+// never observe a half-written config.
+func (f *File) Save(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(f.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil {
+		_ = os.Chmod(tmp.Name(), info.Mode())
+	} else {
+		_ = os.Chmod(tmp.Name(), 0644)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (f *File) Sections() []*Section {
+	return f.sections
+}
+
+func (f *File) SectionNames() []string {
+	names := make([]string, 0, len(f.sections))
+	for _, s := range f.sections {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+func (f *File) Section(name string) *Section {
+	for _, s := range f.sections {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// AddSection returns the named section, appending it to the end of the file
+// if it does not exist yet.
+func (f *File) AddSection(name string) *Section {
+	if s := f.Section(name); s != nil {
+		return s
+	}
+	f.ensureTrailingBlank()
+	s := &Section{name: name, header: "[" + name + "]"}
+	f.sections = append(f.sections, s)
+	f.trailing = true
+	return s
+}
+
+// RemoveSection drops a section and every line under it.
+func (f *File) RemoveSection(name string) bool {
+	for i, s := range f.sections {
+		if s.name == name {
+			f.sections = append(f.sections[:i], f.sections[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// RenameSection rewrites a section header in place, keeping any comment that
+// follows the closing bracket.
+func (f *File) RenameSection(from, to string) bool {
+	s := f.Section(from)
+	if s == nil || f.Section(to) != nil {
+		return false
+	}
+	open := strings.Index(s.header, "[")
+	end := strings.Index(s.header, "]")
+	s.header = s.header[:open] + "[" + to + s.header[end:]
+	s.name = to
+	return true
+}
+
+// CloneSection appends a copy of section from named to. Trailing blank and
+// comment lines are left behind since they usually introduce whatever
+// follows the section rather than belong to it.
+func (f *File) CloneSection(from, to string) *Section {
+	src := f.Section(from)
+	if src == nil || f.Section(to) != nil {
+		return nil
+	}
+	last := len(src.lines)
+	for last > 0 && src.lines[last-1].kind != lineKey {
+		last--
+	}
+
+	dst := f.AddSection(to)
+	for _, l := range src.lines[:last] {
+		copied := *l
+		dst.lines = append(dst.lines, &copied)
+	}
+	return dst
+}
+
+// ensureTrailingBlank separates a new section from the previous one with a
+// blank line, matching how the spicetify CLI lays the file out.
+func (f *File) ensureTrailingBlank() {
+	var lines *[]*line
+	if len(f.sections) > 0 {
+		lines = &f.sections[len(f.sections)-1].lines
+	} else {
+		lines = &f.preamble
+	}
+	if len(*lines) == 0 && len(f.sections) == 0 {
+		return
+	}
+	if n := len(*lines); n == 0 || (*lines)[n-1].kind != lineBlank {
+		*lines = append(*lines, &line{kind: lineBlank})
+	}
+}
+
+func (f *File) Get(section, key string) (string, bool) {
+	s := f.Section(section)
+	if s == nil {
+		return "", false
+	}
+	return s.Get(key)
+}
+
+func (f *File) Set(section, key, value string) {
+	f.AddSection(section).Set(key, value)
+}
+
+// Lookup finds key in whichever section holds it first, the same way the
+// spicetify CLI resolves `spicetify config <key>`.
+func (f *File) Lookup(key string) (section, value string, ok bool) {
+	for _, s := range f.sections {
+		if v, found := s.Get(key); found {
+			return s.name, v, true
+		}
+	}
+	return "", "", false
+}
+
+func (s *Section) Name() string {
+	return s.name
+}
+
+func (s *Section) Keys() []string {
+	var keys []string
+	for _, l := range s.lines {
+		if l.kind == lineKey {
+			keys = append(keys, l.key)
+		}
+	}
+	return keys
+}
+
+func (s *Section) find(key string) *line {
+	for _, l := range s.lines {
+		if l.kind == lineKey && l.key == key {
+			return l
+		}
+	}
+	return nil
+}
+
+func (s *Section) Get(key string) (string, bool) {
+	if l := s.find(key); l != nil {
+		return l.value, true
+	}
+	return "", false
+}
+
+func (s *Section) Has(key string) bool {
+	return s.find(key) != nil
+}
+
+// Set updates key in place, keeping its alignment and inline comment. New
+// keys are inserted after the last key of the section and padded to line up
+// with their neighbours.
+func (s *Section) Set(key, value string) {
+	if l := s.find(key); l != nil {
+		// "key =" with an empty value has no space to separate a new value.
+		if value != "" && strings.HasSuffix(l.prefix, " =") {
+			l.prefix += " "
+		}
+		l.value = value
+		return
+	}
+
+	l := &line{kind: lineKey, key: key, value: value, prefix: s.keyPrefix(key)}
+	insertAt := len(s.lines)
+	for insertAt > 0 && s.lines[insertAt-1].kind != lineKey {
+		insertAt--
+	}
+	s.lines = append(s.lines[:insertAt], append([]*line{l}, s.lines[insertAt:]...)...)
+}
+
+// keyPrefix pads a new key to the column every other key in the section
+// lines up on, or falls back to "key = " when the section is not aligned.
+func (s *Section) keyPrefix(key string) string {
+	column := -1
+	for _, l := range s.lines {
+		if l.kind != lineKey {
+			continue
+		}
+		eq := strings.Index(l.prefix, "=")
+		if column >= 0 && eq != column {
+			column = -1
+			break
+		}
+		column = eq
+	}
+	if column <= len(key) {
+		return key + " = "
+	}
+	return key + strings.Repeat(" ", column-len(key)) + "= "
+}
+
+func (s *Section) Delete(key string) bool {
+	for i, l := range s.lines {
+		if l.kind == lineKey && l.key == key {
+			s.lines = append(s.lines[:i], s.lines[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Section) String() string {
+	return fmt.Sprintf("[%s]", s.name)
+}
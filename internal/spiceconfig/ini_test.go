@@ -0,0 +1,245 @@
+package spiceconfig
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const sampleConfig = `; Spicetify config, edited by hand
+[Setting]
+spotify_path            = /opt/spotify
+prefs_path              = /home/me/.config/spotify/prefs
+current_theme           = Sleek ; picked in March
+color_scheme            = Nord
+inject_css              = 1
+
+# preprocessing
+[Preprocesses]
+disable_sentry     = 1
+expose_apis        = 1
+
+[AdditionalOptions]
+extensions            = fullAppDisplay.js|shuffle+.js
+custom_apps           = marketplace
+sidebar_config        = 1
+
+[Patch]
+xpui.js_find_8008 = ,(\w+=)32,
+xpui.js_repl_8008 = ,${1}56,
+
+[Backup]
+version = 1.2.31.1205.g4d59ad7c
+with    = 2.36.11
+`
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"sample", sampleConfig},
+		{"crlf", strings.ReplaceAll(sampleConfig, "\n", "\r\n")},
+		{"bom", bom + sampleConfig},
+		{"bom and crlf", bom + strings.ReplaceAll(sampleConfig, "\n", "\r\n")},
+		{"no trailing newline", strings.TrimSuffix(sampleConfig, "\n")},
+		{"preamble only", "; nothing but a comment\n\n"},
+		{"inline comments", "[Nord]\ntext   = eceff4 ; snow storm\nmain   = 2e3440\t# polar night\naccent = #88c0d0\nlist   = a;b\n"},
+		{"unparsed lines", "[Setting]\njust some text\n  = no key\ncurrent_theme=Sleek\n"},
+		{"header comment", "[Setting] ; main section\nkey = value\n"},
+		{"blank runs and indentation", "\n\n[Setting]\n\n\n   indented = yes   \n\t\ntabbed\t=\tvalue\n"},
+		{"empty values", "[AdditionalOptions]\nextensions =\ncustom_apps = \n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(Parse([]byte(tt.data)).Bytes())
+			if got != tt.data {
+				t.Errorf("round trip changed the file\ngot:  %q\nwant: %q", got, tt.data)
+			}
+		})
+	}
+}
+
+func TestRoundTripAfterEdit(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		section string
+		key     string
+		value   string
+		want    string
+	}{
+		{
+			name:    "aligned key keeps column and comment",
+			data:    sampleConfig,
+			section: "Setting",
+			key:     "current_theme",
+			value:   "Dribbblish",
+			want:    strings.Replace(sampleConfig, "= Sleek ; picked in March", "= Dribbblish ; picked in March", 1),
+		},
+		{
+			name:    "crlf and bom survive an edit",
+			data:    bom + strings.ReplaceAll(sampleConfig, "\n", "\r\n"),
+			section: "Setting",
+			key:     "color_scheme",
+			value:   "Frost",
+			want:    bom + strings.ReplaceAll(strings.Replace(sampleConfig, "= Nord", "= Frost", 1), "\n", "\r\n"),
+		},
+		{
+			name:    "tab comment separator kept",
+			data:    "[Nord]\nmain   = 2e3440\t# polar night\n",
+			section: "Nord",
+			key:     "main",
+			value:   "3b4252",
+			want:    "[Nord]\nmain   = 3b4252\t# polar night\n",
+		},
+		{
+			name:    "empty value gains a space",
+			data:    "[AdditionalOptions]\nextensions =\n",
+			section: "AdditionalOptions",
+			key:     "extensions",
+			value:   "a.js",
+			want:    "[AdditionalOptions]\nextensions = a.js\n",
+		},
+		{
+			name:    "new key lines up with its section",
+			data:    sampleConfig,
+			section: "Preprocesses",
+			key:     "remove_rtl_rule",
+			value:   "1",
+			want:    strings.Replace(sampleConfig, "expose_apis        = 1\n", "expose_apis        = 1\nremove_rtl_rule    = 1\n", 1),
+		},
+		{
+			name:    "new key in unaligned section",
+			data:    "[Nord]\ntext = eceff4\nmain=2e3440\n\n[Frost]\n",
+			section: "Nord",
+			key:     "accent",
+			value:   "88c0d0",
+			want:    "[Nord]\ntext = eceff4\nmain=2e3440\naccent = 88c0d0\n\n[Frost]\n",
+		},
+		{
+			name:    "new section separated by a blank line",
+			data:    "[Nord]\ntext = eceff4\n",
+			section: "Frost",
+			key:     "text",
+			value:   "ffffff",
+			want:    "[Nord]\ntext = eceff4\n\n[Frost]\ntext = ffffff\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Parse([]byte(tt.data))
+			f.Set(tt.section, tt.key, tt.value)
+			if got := string(f.Bytes()); got != tt.want {
+				t.Errorf("got:  %q\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseValuesAndOrder(t *testing.T) {
+	f := Parse([]byte(sampleConfig))
+
+	wantSections := []string{"Setting", "Preprocesses", "AdditionalOptions", "Patch", "Backup"}
+	if got := f.SectionNames(); !slices.Equal(got, wantSections) {
+		t.Errorf("sections = %v, want %v", got, wantSections)
+	}
+	wantKeys := []string{"spotify_path", "prefs_path", "current_theme", "color_scheme", "inject_css"}
+	if got := f.Section("Setting").Keys(); !slices.Equal(got, wantKeys) {
+		t.Errorf("Setting keys = %v, want %v", got, wantKeys)
+	}
+
+	values := []struct {
+		section, key, want string
+	}{
+		{"Setting", "current_theme", "Sleek"},
+		{"Setting", "spotify_path", "/opt/spotify"},
+		{"AdditionalOptions", "extensions", "fullAppDisplay.js|shuffle+.js"},
+		{"Patch", "xpui.js_repl_8008", ",${1}56,"},
+		{"Backup", "with", "2.36.11"},
+	}
+	for _, v := range values {
+		if got, ok := f.Get(v.section, v.key); !ok || got != v.want {
+			t.Errorf("Get(%s, %s) = %q, %v; want %q", v.section, v.key, got, ok, v.want)
+		}
+	}
+
+	colors := Parse([]byte("[Nord]\naccent = #88c0d0\nlist = a;b\ntext = eceff4 ; comment\n"))
+	for key, want := range map[string]string{"accent": "#88c0d0", "list": "a;b", "text": "eceff4"} {
+		if got, _ := colors.Get("Nord", key); got != want {
+			t.Errorf("Get(Nord, %s) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestSectionEdits(t *testing.T) {
+	data := "[Nord] ; cold\ntext = eceff4\nmain = 2e3440\n\n; next up\n[Frost]\ntext = ffffff\n"
+
+	f := Parse([]byte(data))
+	if !f.RenameSection("Nord", "Arctic") {
+		t.Fatal("RenameSection failed")
+	}
+	if got, want := string(f.Bytes()), strings.Replace(data, "[Nord]", "[Arctic]", 1); got != want {
+		t.Errorf("rename: got %q, want %q", got, want)
+	}
+	if f.RenameSection("Arctic", "Frost") {
+		t.Error("RenameSection onto an existing section succeeded")
+	}
+
+	f = Parse([]byte(data))
+	if f.CloneSection("Nord", "Nord Copy") == nil {
+		t.Fatal("CloneSection failed")
+	}
+	want := data + "\n[Nord Copy]\ntext = eceff4\nmain = 2e3440\n"
+	if got := string(f.Bytes()); got != want {
+		t.Errorf("clone: got %q, want %q", got, want)
+	}
+
+	f = Parse([]byte(data))
+	if !f.RemoveSection("Nord") || f.RemoveSection("Nord") {
+		t.Error("RemoveSection should succeed once")
+	}
+	if got, want := string(f.Bytes()), "[Frost]\ntext = ffffff\n"; got != want {
+		t.Errorf("remove: got %q, want %q", got, want)
+	}
+
+	f = Parse([]byte(data))
+	if !f.Section("Nord").Delete("text") {
+		t.Fatal("Delete failed")
+	}
+	if got, want := string(f.Bytes()), strings.Replace(data, "text = eceff4\n", "", 1); got != want {
+		t.Errorf("delete: got %q, want %q", got, want)
+	}
+}
+
+func TestSaveKeepsBytesAndMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config-xpui.ini")
+	data := bom + strings.ReplaceAll(sampleConfig, "\n", "\r\n")
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != data {
+		t.Errorf("Save changed the file\ngot:  %q\nwant: %q", got, data)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Save changed the mode to %v (%v)", info.Mode().Perm(), err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("Save left %d files behind, want 1", len(entries))
+	}
+}
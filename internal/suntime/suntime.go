@@ -0,0 +1,73 @@
+// Package suntime computes sunrise and sunset with the sunrise equation.
+// Results are accurate to a minute or two, which is plenty for switching
+// color schemes.
+package suntime
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	j2000         = 2451545.0
+	unixEpochJD   = 2440587.5
+	earthTilt     = 23.4397
+	sunAltitude   = -0.833 // refraction plus the sun's radius
+	secondsPerDay = 86400
+)
+
+func rad(deg float64) float64 { return deg * math.Pi / 180 }
+func deg(rad float64) float64 { return rad * 180 / math.Pi }
+
+func julian(t time.Time) float64 {
+	return float64(t.Unix())/secondsPerDay + unixEpochJD
+}
+
+func fromJulian(jd float64, loc *time.Location) time.Time {
+	return time.Unix(int64(math.Round((jd-unixEpochJD)*secondsPerDay)), 0).In(loc)
+}
+
+// Times returns sunrise and sunset on the calendar day of day, in day's
+// location. Longitude is positive east. ok is false during polar day or
+// night, when the sun does not cross the horizon.
+func Times(day time.Time, latitude, longitude float64) (sunrise, sunset time.Time, ok bool) {
+	loc := day.Location()
+	year, month, date := day.Date()
+	target := time.Date(year, month, date, 0, 0, 0, 0, time.UTC)
+
+	// Start from the solar day whose UTC noon falls on the local date, then
+	// move to the one whose solar noon does. The two differ where the civil
+	// date runs ahead of or behind the sun, as at UTC+13 and +14.
+	n := math.Round(julian(target.Add(12*time.Hour)) - j2000 + 0.0008)
+	transit, declination := solarNoon(n, longitude)
+	for i := 0; i < 2; i++ {
+		y, m, d := fromJulian(transit, loc).Date()
+		shift := math.Round(target.Sub(time.Date(y, m, d, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+		if shift == 0 {
+			break
+		}
+		n += shift
+		transit, declination = solarNoon(n, longitude)
+	}
+
+	cosHourAngle := (math.Sin(rad(sunAltitude)) - math.Sin(rad(latitude))*math.Sin(declination)) /
+		(math.Cos(rad(latitude)) * math.Cos(declination))
+	if cosHourAngle < -1 || cosHourAngle > 1 {
+		return time.Time{}, time.Time{}, false
+	}
+	hourAngle := deg(math.Acos(cosHourAngle))
+
+	return fromJulian(transit-hourAngle/360, loc), fromJulian(transit+hourAngle/360, loc), true
+}
+
+// solarNoon returns the Julian date of solar noon on day n after J2000 at
+// longitude, and the sun's declination then.
+func solarNoon(n, longitude float64) (transit, declination float64) {
+	meanSolarTime := n - longitude/360
+	anomaly := math.Mod(357.5291+0.98560028*meanSolarTime, 360)
+	center := 1.9148*math.Sin(rad(anomaly)) + 0.02*math.Sin(rad(2*anomaly)) + 0.0003*math.Sin(rad(3*anomaly))
+	eclipticLongitude := math.Mod(anomaly+center+180+102.9372, 360)
+	transit = j2000 + meanSolarTime + 0.0053*math.Sin(rad(anomaly)) - 0.0069*math.Sin(rad(2*eclipticLongitude))
+	declination = math.Asin(math.Sin(rad(eclipticLongitude)) * math.Sin(rad(earthTilt)))
+	return transit, declination
+}
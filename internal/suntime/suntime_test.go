@@ -0,0 +1,77 @@
+package suntime
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestTimes(t *testing.T) {
+	tests := []struct {
+		name                string
+		zone                string
+		date                string
+		latitude, longitude float64
+		sunrise, sunset     string // local HH:MM, empty when ok is false
+	}{
+		{"london midsummer", "Europe/London", "2024-06-21", 51.5074, -0.1278, "04:43", "21:21"},
+		{"london midwinter", "Europe/London", "2024-12-21", 51.5074, -0.1278, "08:03", "15:53"},
+		{"auckland", "Pacific/Auckland", "2024-12-21", -36.8485, 174.7633, "05:58", "20:40"},
+		{"honolulu", "Pacific/Honolulu", "2024-03-20", 21.3069, -157.8583, "06:35", "18:42"},
+		{"kiritimati", "Pacific/Kiritimati", "2024-03-20", 1.8721, -157.4278, "06:34", "18:40"},
+		{"tromso polar night", "Europe/Oslo", "2024-12-21", 69.6492, 18.9553, "", ""},
+		{"tromso midnight sun", "Europe/Oslo", "2024-06-21", 69.6492, 18.9553, "", ""},
+	}
+	const tolerance = 3 * time.Minute
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, err := time.LoadLocation(tt.zone)
+			if err != nil {
+				t.Fatal(err)
+			}
+			day, err := time.ParseInLocation("2006-01-02 15:04", tt.date+" 09:00", loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sunrise, sunset, ok := Times(day, tt.latitude, tt.longitude)
+			if tt.sunrise == "" {
+				if ok {
+					t.Errorf("got sunrise %v and sunset %v, want none", sunrise, sunset)
+				}
+				return
+			}
+			if !ok {
+				t.Fatal("got no sunrise or sunset")
+			}
+			for _, c := range []struct {
+				what string
+				got  time.Time
+				want string
+			}{{"sunrise", sunrise, tt.sunrise}, {"sunset", sunset, tt.sunset}} {
+				want, _ := time.ParseInLocation("2006-01-02 15:04", tt.date+" "+c.want, loc)
+				if c.got.Location() != loc {
+					t.Errorf("%s is in %v, want %v", c.what, c.got.Location(), loc)
+				}
+				if diff := c.got.Sub(want); diff < -tolerance || diff > tolerance {
+					t.Errorf("%s = %s, want %s (±%v)", c.what, c.got.Format("2006-01-02 15:04:05"), want.Format("2006-01-02 15:04"), tolerance)
+				}
+			}
+		})
+	}
+}
+
+func TestTimesUsesCalendarDayNotInstant(t *testing.T) {
+	loc, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Fatal(err)
+	}
+	early := time.Date(2024, 12, 21, 0, 30, 0, 0, loc)
+	late := time.Date(2024, 12, 21, 23, 30, 0, 0, loc)
+	r1, s1, _ := Times(early, -36.8485, 174.7633)
+	r2, s2, _ := Times(late, -36.8485, 174.7633)
+	if !r1.Equal(r2) || !s1.Equal(s2) {
+		t.Errorf("same day gave %v/%v and %v/%v", r1, s1, r2, s2)
+	}
+}
@@ -0,0 +1,281 @@
+// Package wshub fans messages out to websocket clients. Every client gets
+// its own buffered send queue and writer goroutine, so a slow or stuck
+// client is dropped instead of holding up the others.
+package wshub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = pongWait * 9 / 10
+	maxMessageSize = 1 << 20
+	sendQueueSize  = 64
+)
+
+// ErrClosed is returned when sending to a client that has disconnected.
+var ErrClosed = errors.New("wshub: client disconnected")
+
+// Client is one connected websocket.
+type Client struct {
+	hub  *Hub
+	conn *websocket.Conn
+	send chan []byte
+	done chan struct{}
+	once sync.Once
+}
+
+// Hub tracks connected clients. The zero value is not usable; call New.
+type Hub struct {
+	mu      sync.Mutex
+	clients map[*Client]struct{}
+	closed  bool
+	writers sync.WaitGroup
+
+	// Keepalive timing, fixed by New; tests shorten it.
+	writeWait  time.Duration
+	pongWait   time.Duration
+	pingPeriod time.Duration
+
+	// OnMessage, if set, is called from the client's read goroutine for
+	// every text or binary message it sends.
+	OnMessage func(c *Client, data []byte)
+
+	// OnDisconnect, if set, is called once a client's read loop ends.
+	OnDisconnect func(c *Client)
+}
+
+func New() *Hub {
+	return &Hub{
+		clients:    make(map[*Client]struct{}),
+		writeWait:  writeWait,
+		pongWait:   pongWait,
+		pingPeriod: pingPeriod,
+	}
+}
+
+// Serve registers conn and runs its read loop until the connection drops
+// or the hub is closed. It takes ownership of conn.
+func (h *Hub) Serve(conn *websocket.Conn) {
+	c := &Client{
+		hub:  h,
+		conn: conn,
+		send: make(chan []byte, sendQueueSize),
+		done: make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		conn.Close()
+		return
+	}
+	h.clients[c] = struct{}{}
+	h.writers.Add(1)
+	h.mu.Unlock()
+
+	go c.writeLoop()
+	c.readLoop()
+}
+
+// Broadcast encodes v once and queues it for every client. Clients whose
+// queue is full are disconnected.
+func (h *Hub) Broadcast(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		if !c.enqueue(data) {
+			h.dropSlowLocked(c)
+		}
+	}
+	return nil
+}
+
+// Send queues v for a single client.
+func (c *Client) Send(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+	if _, ok := c.hub.clients[c]; !ok {
+		return ErrClosed
+	}
+	if !c.enqueue(data) {
+		c.hub.dropSlowLocked(c)
+		return ErrClosed
+	}
+	return nil
+}
+
+// RemoteAddr reports the client's address.
+func (c *Client) RemoteAddr() string {
+	return c.conn.RemoteAddr().String()
+}
+
+// Len reports how many clients are connected.
+func (h *Hub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// Clients returns a snapshot of the connected clients.
+func (h *Hub) Clients() []*Client {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	clients := make([]*Client, 0, len(h.clients))
+	for c := range h.clients {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+// Close disconnects every client and refuses new ones.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for c := range h.clients {
+		h.dropLocked(c)
+	}
+}
+
+// Shutdown closes the hub and waits until queued messages are written or
+// ctx expires.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.Close()
+	flushed := make(chan struct{})
+	go func() {
+		h.writers.Wait()
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (h *Hub) dropLocked(c *Client) {
+	delete(h.clients, c)
+	c.once.Do(func() { close(c.done) })
+}
+
+// dropSlowLocked drops a client that fell behind. Its writer is most likely
+// stuck in a write, so the connection is closed now rather than after the
+// write deadline.
+func (h *Hub) dropSlowLocked(c *Client) {
+	h.dropLocked(c)
+	if c.conn != nil {
+		c.conn.Close()
+	}
+}
+
+func (h *Hub) drop(c *Client) {
+	h.mu.Lock()
+	h.dropLocked(c)
+	h.mu.Unlock()
+}
+
+func (c *Client) enqueue(data []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.send <- data:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Client) readLoop() {
+	defer func() {
+		c.hub.drop(c)
+		c.conn.Close()
+		if c.hub.OnDisconnect != nil {
+			c.hub.OnDisconnect(c)
+		}
+	}()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(c.hub.pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(c.hub.pongWait))
+	})
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if c.hub.OnMessage != nil {
+			c.hub.OnMessage(c, data)
+		}
+	}
+}
+
+// writeLoop is the only goroutine that writes to the connection. It exits
+// once the client is dropped, after flushing what is queued and trying to
+// send a close frame.
+func (c *Client) writeLoop() {
+	ticker := time.NewTicker(c.hub.pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+		c.hub.writers.Done()
+	}()
+
+	for {
+		select {
+		case data := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				c.hub.drop(c)
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.hub.drop(c)
+				return
+			}
+		case <-c.done:
+			c.flush()
+			msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "")
+			c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(c.hub.writeWait))
+			return
+		}
+	}
+}
+
+func (c *Client) flush() {
+	for {
+		select {
+		case data := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
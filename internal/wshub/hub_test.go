@@ -0,0 +1,275 @@
+package wshub
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// serve runs h behind a test server and returns its websocket URL.
+func serve(t *testing.T, h *Hub) string {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		h.Serve(conn)
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func dial(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// waitFor polls cond until it holds or the timeout passes.
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func shutdown(t *testing.T, h *Hub) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
+
+// fakeClient registers a client with no connection or writer, so its queue
+// only fills.
+func fakeClient(h *Hub, queue int) *Client {
+	c := &Client{hub: h, send: make(chan []byte, queue), done: make(chan struct{})}
+	h.mu.Lock()
+	h.clients[c] = struct{}{}
+	h.mu.Unlock()
+	return c
+}
+
+func TestBroadcastDropsClientWithFullQueue(t *testing.T) {
+	h := New()
+	slow := fakeClient(h, 2)
+	fast := fakeClient(h, 8)
+
+	for i := 0; i < 3; i++ {
+		if err := h.Broadcast(i); err != nil {
+			t.Fatalf("Broadcast: %v", err)
+		}
+	}
+
+	h.mu.Lock()
+	_, slowKept := h.clients[slow]
+	_, fastKept := h.clients[fast]
+	h.mu.Unlock()
+	if slowKept {
+		t.Error("client with a full queue was kept")
+	}
+	if !fastKept {
+		t.Error("client with room in its queue was dropped")
+	}
+	select {
+	case <-slow.done:
+	default:
+		t.Error("dropped client was not signalled to close")
+	}
+	if got := len(fast.send); got != 3 {
+		t.Errorf("fast client queued %d messages, want 3", got)
+	}
+	if err := slow.Send("late"); err != ErrClosed {
+		t.Errorf("Send to dropped client = %v, want ErrClosed", err)
+	}
+}
+
+func TestStalledConnectionIsDropped(t *testing.T) {
+	h := New()
+	url := serve(t, h)
+
+	dial(t, url) // never reads
+	reader := dial(t, url)
+	waitFor(t, time.Second, "both clients", func() bool { return h.Len() == 2 })
+
+	var received atomic.Int64
+	go func() {
+		for {
+			if _, _, err := reader.ReadMessage(); err != nil {
+				return
+			}
+			received.Add(1)
+		}
+	}()
+
+	// Large messages fill the stalled client's socket buffers, then its
+	// queue, while the reading client keeps up.
+	payload := strings.Repeat("x", 256<<10)
+	sent := int64(0)
+	waitFor(t, 10*time.Second, "stalled client to be dropped", func() bool {
+		if err := h.Broadcast(payload); err != nil {
+			t.Fatalf("Broadcast: %v", err)
+		}
+		sent++
+		waitFor(t, 5*time.Second, "reader to catch up", func() bool { return received.Load() == sent })
+		return h.Len() == 1
+	})
+	shutdown(t, h)
+}
+
+func TestBroadcastNeverBlocks(t *testing.T) {
+	h := New()
+	url := serve(t, h)
+	for i := 0; i < 3; i++ {
+		dial(t, url) // none of them read
+	}
+	waitFor(t, time.Second, "clients", func() bool { return h.Len() == 3 })
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		payload := strings.Repeat("x", 64<<10)
+		for i := 0; i < 1000; i++ {
+			_ = h.Broadcast(payload)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Broadcast blocked on clients that do not read")
+	}
+	shutdown(t, h)
+}
+
+func TestKeepalive(t *testing.T) {
+	h := New()
+	h.pingPeriod = 20 * time.Millisecond
+	h.pongWait = 100 * time.Millisecond
+	url := serve(t, h)
+
+	// The dialer's default ping handler answers with a pong whenever the
+	// connection is being read.
+	alive := dial(t, url)
+	var pings atomic.Int64
+	alive.SetPingHandler(func(data string) error {
+		pings.Add(1)
+		return alive.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	go func() {
+		for {
+			if _, _, err := alive.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	waitFor(t, time.Second, "client", func() bool { return h.Len() == 1 })
+
+	// A client that never reads never answers pings and must be dropped
+	// once the read deadline passes.
+	dial(t, url)
+	waitFor(t, time.Second, "second client", func() bool { return h.Len() == 2 })
+	waitFor(t, 2*time.Second, "silent client to time out", func() bool { return h.Len() == 1 })
+
+	// Several pong deadlines later the answering client is still there.
+	time.Sleep(5 * h.pongWait)
+	if h.Len() != 1 {
+		t.Fatalf("answering client was dropped")
+	}
+	if pings.Load() < 5 {
+		t.Errorf("got %d pings, want at least 5", pings.Load())
+	}
+	shutdown(t, h)
+}
+
+func TestShutdownFlushesAndClosesClients(t *testing.T) {
+	h := New()
+	url := serve(t, h)
+	clients := []*websocket.Conn{dial(t, url), dial(t, url)}
+	waitFor(t, time.Second, "clients", func() bool { return h.Len() == 2 })
+
+	const messages = 10
+	for i := 0; i < messages; i++ {
+		if err := h.Broadcast(i); err != nil {
+			t.Fatalf("Broadcast: %v", err)
+		}
+	}
+	shutdown(t, h)
+
+	for i, conn := range clients {
+		got := 0
+		for {
+			conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			_, _, err := conn.ReadMessage()
+			if err != nil {
+				if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+					t.Errorf("client %d: read ended with %v, want a going-away close", i, err)
+				}
+				break
+			}
+			got++
+		}
+		if got != messages {
+			t.Errorf("client %d got %d messages before closing, want %d", i, got, messages)
+		}
+	}
+	if h.Len() != 0 {
+		t.Errorf("%d clients left after Shutdown", h.Len())
+	}
+
+	// A closed hub turns new connections away.
+	late := dial(t, url)
+	late.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := late.ReadMessage(); err == nil {
+		t.Error("closed hub accepted a new client")
+	}
+}
+
+func TestMessageAndDisconnectCallbacks(t *testing.T) {
+	h := New()
+	messages := make(chan string, 1)
+	disconnected := make(chan *Client, 1)
+	h.OnMessage = func(c *Client, data []byte) {
+		messages <- string(data)
+		_ = c.Send("pong")
+	}
+	h.OnDisconnect = func(c *Client) { disconnected <- c }
+	url := serve(t, h)
+
+	conn := dial(t, url)
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("ping")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if got := <-messages; got != "ping" {
+		t.Errorf("OnMessage got %q, want ping", got)
+	}
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != `"pong"` {
+		t.Errorf("reply = %q, %v; want \"pong\"", data, err)
+	}
+
+	conn.Close()
+	select {
+	case <-disconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnDisconnect was not called")
+	}
+	waitFor(t, time.Second, "client removal", func() bool { return h.Len() == 0 })
+	shutdown(t, h)
+}